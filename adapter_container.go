@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// TrivyReport is the JSON report of Trivy
+type TrivyReport struct {
+	ArtifactName string `json:"ArtifactName"`
+	Metadata     struct {
+		OS *struct {
+			Family string `json:"Family"`
+			Name   string `json:"Name"`
+		} `json:"OS"`
+	} `json:"Metadata"`
+	Results []struct {
+		Target          string               `json:"Target"`
+		Vulnerabilities []TrivyVulnerability `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// TrivyVulnerability is a vulnerable package found by Trivy
+type TrivyVulnerability struct {
+	VulnerabilityID  string   `json:"VulnerabilityID"`
+	PkgName          string   `json:"PkgName"`
+	InstalledVersion string   `json:"InstalledVersion"`
+	FixedVersion     string   `json:"FixedVersion"`
+	PrimaryURL       string   `json:"PrimaryURL"`
+	Title            string   `json:"Title"`
+	Description      string   `json:"Description"`
+	Severity         string   `json:"Severity"`
+	CweIDs           []string `json:"CweIDs"`
+	References       []string `json:"References"`
+}
+
+// GrypeReport is the JSON report of Grype
+type GrypeReport struct {
+	Matches []GrypeMatch `json:"matches"`
+	Source  struct {
+		Target json.RawMessage `json:"target"`
+	} `json:"source"`
+	Distro struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"distro"`
+	Descriptor struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"descriptor"`
+}
+
+// GrypeMatch is a vulnerable package found by Grype
+type GrypeMatch struct {
+	Vulnerability struct {
+		ID          string   `json:"id"`
+		DataSource  string   `json:"dataSource"`
+		Severity    string   `json:"severity"`
+		URLs        []string `json:"urls"`
+		Description string   `json:"description"`
+		Fix         struct {
+			Versions []string `json:"versions"`
+		} `json:"fix"`
+	} `json:"vulnerability"`
+	Artifact struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"artifact"`
+}
+
+// convertTrivy turns a Trivy JSON report into a container scanning report
+func convertTrivy(b []byte) (report.Report, error) {
+	r := containerScanningReport("trivy", "Trivy", "")
+
+	var trivy TrivyReport
+	if err := json.Unmarshal(b, &trivy); err != nil {
+		return r, err
+	}
+
+	var osName string
+	if trivy.Metadata.OS != nil {
+		osName = operatingSystem(trivy.Metadata.OS.Family, trivy.Metadata.OS.Name)
+	}
+
+	for _, result := range trivy.Results {
+		for _, tv := range result.Vulnerabilities {
+			links := tv.References
+			if tv.PrimaryURL != "" {
+				links = append([]string{tv.PrimaryURL}, links...)
+			}
+			var fixes []string
+			if tv.FixedVersion != "" {
+				fixes = []string{tv.FixedVersion}
+			}
+
+			v := containerVulnerability(containerFinding{
+				scanner:     report.Scanner{ID: "trivy", Name: "Trivy"},
+				id:          tv.VulnerabilityID,
+				title:       tv.Title,
+				description: tv.Description,
+				severity:    tv.Severity,
+				image:       trivy.ArtifactName,
+				os:          osName,
+				pkg:         tv.PkgName,
+				version:     tv.InstalledVersion,
+				fixes:       fixes,
+				cwes:        tv.CweIDs,
+				links:       links,
+			})
+			r.Vulnerabilities = append(r.Vulnerabilities, v)
+		}
+	}
+
+	return r, nil
+}
+
+// convertGrype turns a Grype JSON report into a container scanning report
+func convertGrype(b []byte) (report.Report, error) {
+	var grype GrypeReport
+	if err := json.Unmarshal(b, &grype); err != nil {
+		return report.NewReport(), err
+	}
+
+	r := containerScanningReport("grype", "Grype", grype.Descriptor.Version)
+
+	// the target is an object for images and a plain path for directories
+	var image string
+	var target struct {
+		UserInput string `json:"userInput"`
+	}
+	if err := json.Unmarshal(grype.Source.Target, &target); err == nil {
+		image = target.UserInput
+	} else {
+		_ = json.Unmarshal(grype.Source.Target, &image)
+	}
+
+	osName := operatingSystem(grype.Distro.Name, grype.Distro.Version)
+
+	for _, m := range grype.Matches {
+		links := m.Vulnerability.URLs
+		if m.Vulnerability.DataSource != "" {
+			links = append([]string{m.Vulnerability.DataSource}, links...)
+		}
+
+		v := containerVulnerability(containerFinding{
+			scanner:     report.Scanner{ID: "grype", Name: "Grype"},
+			id:          m.Vulnerability.ID,
+			description: m.Vulnerability.Description,
+			severity:    m.Vulnerability.Severity,
+			image:       image,
+			os:          osName,
+			pkg:         m.Artifact.Name,
+			version:     m.Artifact.Version,
+			fixes:       m.Vulnerability.Fix.Versions,
+			links:       links,
+		})
+		r.Vulnerabilities = append(r.Vulnerabilities, v)
+	}
+
+	return r, nil
+}
+
+// containerFinding holds the fields shared by the container scanners
+type containerFinding struct {
+	scanner     report.Scanner
+	id          string
+	title       string
+	description string
+	severity    string
+	image       string
+	os          string
+	pkg         string
+	version     string
+	fixes       []string
+	cwes        []string
+	links       []string
+}
+
+func containerVulnerability(c containerFinding) report.Vulnerability {
+	name := c.title
+	if name == "" {
+		name = c.id
+	}
+
+	v := report.Vulnerability{
+		Category:    report.CategoryContainerScanning,
+		Name:        name,
+		Message:     fmt.Sprintf("%s in %s-%s", c.id, c.pkg, c.version),
+		Description: c.description,
+		CompareKey:  strings.Join([]string{c.os, c.pkg, c.id}, ":"),
+		Severity:    containerSeverity(c.severity),
+		Confidence:  report.ConfidenceLevelUnknown,
+		Scanner:     c.scanner,
+		Location: report.Location{
+			Image:           c.image,
+			OperatingSystem: c.os,
+			Dependency: &report.Dependency{
+				Package: report.Package{Name: c.pkg},
+				Version: c.version,
+			},
+		},
+		Links: report.NewLinks(uniqueStrings(c.links)...),
+	}
+	if len(c.fixes) > 0 {
+		v.Solution = fmt.Sprintf("Upgrade %s to %s", c.pkg, strings.Join(c.fixes, " or "))
+	}
+
+	if id, ok := report.ParseIdentifierID(c.id); ok {
+		v.Identifiers = append(v.Identifiers, id)
+	} else if c.id != "" {
+		v.Identifiers = append(v.Identifiers, report.Identifier{
+			Type:  report.IdentifierType(c.scanner.ID + "_id"),
+			Name:  c.id,
+			Value: c.id,
+		})
+	}
+	for _, cwe := range c.cwes {
+		if id, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(cwe), "CWE-")); err == nil {
+			v.Identifiers = append(v.Identifiers, report.CWEIdentifier(id))
+		}
+	}
+
+	return v
+}
+
+func containerScanningReport(id, name, version string) report.Report {
+	r := report.NewReport()
+	r.Scan = report.Scan{
+		Scanner: report.ScannerDetails{ID: id, Name: name, Version: version, Vendor: report.Vendor{Name: name}},
+		Type:    report.CategoryContainerScanning,
+		Status:  report.StatusSuccess,
+	}
+	return r
+}
+
+// containerSeverity parses the severity of a container scanner, mapping Grype's "Negligible" onto Info
+func containerSeverity(s string) report.SeverityLevel {
+	if strings.EqualFold(s, "negligible") {
+		return report.SeverityLevelInfo
+	}
+	return report.ParseSeverityLevel(s)
+}
+
+// operatingSystem formats an operating system the way GitLab does, e.g. debian:10
+func operatingSystem(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + ":" + version
+}
+
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range list {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
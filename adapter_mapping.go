@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// Mapping declares how the JSON output of an arbitrary scanner maps onto GitLab vulnerabilities.
+// Results and fields are JSONPath-like selectors, fields being relative to each result.
+type Mapping struct {
+	Name        string            `yaml:"name"`         // Name of the scanner
+	Category    report.Category   `yaml:"category"`     // Category of the findings
+	Files       []string          `yaml:"files"`        // Files are the glob patterns of the report file names the mapping applies to
+	Results     string            `yaml:"results"`      // Results selects the list of results in the document, e.g. $.findings[*]
+	Fields      MappingFields     `yaml:"fields"`       // Fields select the vulnerability fields in a result
+	SeverityMap map[string]string `yaml:"severity_map"` // SeverityMap translates the scanner severities, e.g. blocker: critical
+
+	path      string
+	results   selector
+	selectors map[string]selector
+}
+
+// MappingFields are the selectors of the vulnerability fields
+type MappingFields struct {
+	Name        string              `yaml:"name"`
+	Message     string              `yaml:"message"`
+	Description string              `yaml:"description"`
+	Solution    string              `yaml:"solution"`
+	Severity    string              `yaml:"severity"`
+	Confidence  string              `yaml:"confidence"`
+	CompareKey  string              `yaml:"compare_key"`
+	File        string              `yaml:"file"`
+	LineStart   string              `yaml:"line_start"`
+	LineEnd     string              `yaml:"line_end"`
+	Identifiers []MappingIdentifier `yaml:"identifiers"`
+}
+
+// MappingIdentifier maps an identifier of a result. Type is a literal, Value and Name are selectors.
+type MappingIdentifier struct {
+	Type  string `yaml:"type"`
+	Value string `yaml:"value"`
+	Name  string `yaml:"name"`
+}
+
+// loadMapping reads and compiles a mapping file
+func loadMapping(filename string) (*Mapping, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// a misspelled field would otherwise leave a value silently unmapped
+	m := &Mapping{path: filename}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := m.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return m, nil
+}
+
+func (m *Mapping) compile() error {
+	if m.Name == "" {
+		return errors.New("name is required")
+	}
+	if m.Category == "" {
+		return errors.New("category is required")
+	}
+	if len(m.Files) == 0 {
+		return errors.New("files is required")
+	}
+	for _, pattern := range m.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid files pattern %q: %w", pattern, err)
+		}
+	}
+	for scannerSeverity, severity := range m.SeverityMap {
+		if _, err := parseSeverity(severity); err != nil {
+			return fmt.Errorf("severity_map %s: %w", scannerSeverity, err)
+		}
+	}
+
+	var err error
+	if m.Results == "" {
+		return errors.New("results is required")
+	}
+	if m.results, err = compileSelector(m.Results); err != nil {
+		return fmt.Errorf("results: %w", err)
+	}
+
+	f := m.Fields
+	fields := map[string]string{
+		"name":        f.Name,
+		"message":     f.Message,
+		"description": f.Description,
+		"solution":    f.Solution,
+		"severity":    f.Severity,
+		"confidence":  f.Confidence,
+		"compare_key": f.CompareKey,
+		"file":        f.File,
+		"line_start":  f.LineStart,
+		"line_end":    f.LineEnd,
+	}
+	for i, id := range f.Identifiers {
+		if id.Type == "" || id.Value == "" {
+			return fmt.Errorf("identifiers #%d: type and value are required", i+1)
+		}
+		fields[fmt.Sprintf("identifiers.%d.value", i)] = id.Value
+		fields[fmt.Sprintf("identifiers.%d.name", i)] = id.Name
+	}
+	if f.Name == "" {
+		return errors.New("fields.name is required")
+	}
+
+	m.selectors = make(map[string]selector)
+	for name, path := range fields {
+		if path == "" {
+			continue
+		}
+		if m.selectors[name], err = compileSelector(path); err != nil {
+			return fmt.Errorf("fields.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Matches is true when the mapping applies to the given report file
+func (m *Mapping) Matches(path string) bool {
+	for _, pattern := range m.Files {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert maps the results of a JSON document onto a GitLab report
+func (m *Mapping) Convert(b []byte) (report.Report, error) {
+	r := report.NewReport()
+	id := scannerID(m.Name)
+	r.Scan = report.Scan{
+		Scanner: report.ScannerDetails{ID: id, Name: m.Name, Vendor: report.Vendor{Name: m.Name}},
+		Type:    m.Category,
+		Status:  report.StatusSuccess,
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return r, err
+	}
+
+	results := m.results.Select(doc)
+	if len(results) == 1 {
+		// a selector stopping at the array itself selects its elements
+		if list, ok := results[0].([]interface{}); ok {
+			results = list
+		}
+	}
+
+	for i, result := range results {
+		v, err := m.vulnerability(result)
+		if err != nil {
+			return r, fmt.Errorf("result #%d: %w", i+1, err)
+		}
+		v.Scanner = report.Scanner{ID: id, Name: m.Name}
+		r.Vulnerabilities = append(r.Vulnerabilities, v)
+	}
+
+	return r, nil
+}
+
+func (m *Mapping) vulnerability(result interface{}) (report.Vulnerability, error) {
+	str := func(field string) string {
+		return m.text(field, result)
+	}
+
+	v := report.Vulnerability{
+		Category:    m.Category,
+		Name:        str("name"),
+		Message:     str("message"),
+		Description: str("description"),
+		Solution:    str("solution"),
+		CompareKey:  str("compare_key"),
+		Severity:    report.SeverityLevelUnknown,
+		Confidence:  report.ParseConfidenceLevel(str("confidence")),
+		Location:    report.Location{File: str("file")},
+	}
+
+	if s := str("severity"); s != "" {
+		if mapped, ok := m.SeverityMap[s]; ok {
+			s = mapped
+		} else if mapped, ok := m.SeverityMap[strings.ToLower(s)]; ok {
+			s = mapped
+		}
+		v.Severity = report.ParseSeverityLevel(s)
+	}
+
+	var err error
+	if v.Location.LineStart, err = m.integer("line_start", result); err != nil {
+		return v, err
+	}
+	if v.Location.LineEnd, err = m.integer("line_end", result); err != nil {
+		return v, err
+	}
+
+	for i, id := range m.Fields.Identifiers {
+		value := str(fmt.Sprintf("identifiers.%d.value", i))
+		if value == "" {
+			continue
+		}
+		name := str(fmt.Sprintf("identifiers.%d.name", i))
+		if name == "" {
+			name = value
+		}
+		v.Identifiers = append(v.Identifiers, report.Identifier{
+			Type:  report.IdentifierType(id.Type),
+			Name:  name,
+			Value: value,
+		})
+	}
+
+	if v.CompareKey == "" {
+		parts := []string{v.Location.File, strconv.Itoa(v.Location.LineStart), v.Name}
+		for _, id := range v.Identifiers {
+			parts = append(parts, id.Value)
+		}
+		v.CompareKey = strings.Join(parts, ":")
+	}
+
+	return v, nil
+}
+
+// text returns the first value selected by a field as a string
+func (m *Mapping) text(field string, result interface{}) string {
+	s, ok := m.selectors[field]
+	if !ok {
+		return ""
+	}
+	values := s.Select(result)
+	if len(values) == 0 || values[0] == nil {
+		return ""
+	}
+	switch v := values[0].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(values[0])
+	return string(b)
+}
+
+// integer returns the first value selected by a field as an integer
+func (m *Mapping) integer(field string, result interface{}) (int, error) {
+	s := m.text(field, result)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q is not a number", field, s)
+	}
+	return int(n), nil
+}
+
+// mappingFlag is a flag.Value loading a mapping file, it can be repeated
+type mappingFlag struct {
+	mappings *[]*Mapping
+}
+
+func (f mappingFlag) String() string {
+	if f.mappings == nil {
+		return ""
+	}
+	var paths []string
+	for _, m := range *f.mappings {
+		paths = append(paths, m.path)
+	}
+	return strings.Join(paths, ",")
+}
+
+func (f mappingFlag) Set(s string) error {
+	m, err := loadMapping(s)
+	if err != nil {
+		return err
+	}
+	*f.mappings = append(*f.mappings, m)
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// SARIFInputOptions configures the conversion of SARIF logs into GitLab reports
+type SARIFInputOptions struct {
+	Category report.Category                 // Category is assigned to every converted finding
+	Levels   map[string]report.SeverityLevel // Levels maps SARIF levels (error, warning, note, none) onto severities
+
+	// SecuritySeverity derives severities from the "security-severity" rule property, when set, rather than from Levels
+	SecuritySeverity bool
+}
+
+// NewSARIFInputOptions returns the default SARIF conversion options
+func NewSARIFInputOptions() SARIFInputOptions {
+	return SARIFInputOptions{
+		Category:         report.CategorySast,
+		SecuritySeverity: true,
+		Levels: map[string]report.SeverityLevel{
+			"error":   report.SeverityLevelHigh,
+			"warning": report.SeverityLevelMedium,
+			"note":    report.SeverityLevelLow,
+			"none":    report.SeverityLevelInfo,
+		},
+	}
+}
+
+// nonAlphanumeric matches the characters replaced when turning a tool name into a scanner id
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// sarifCWETag matches CWE tags such as "external/cwe/cwe-089" or "CWE-89"
+var sarifCWETag = regexp.MustCompile(`(?i)(?:^|/)cwe-0*(\d+)$`)
+
+// isSARIF is true when the top level fields of a JSON document are those of a SARIF log
+func isSARIF(fields map[string]json.RawMessage) bool {
+	if _, ok := fields["runs"]; !ok {
+		return false
+	}
+	var schema, version string
+	_ = json.Unmarshal(fields["$schema"], &schema)
+	_ = json.Unmarshal(fields["version"], &version)
+	return strings.Contains(strings.ToLower(schema), "sarif") || strings.HasPrefix(version, "2.")
+}
+
+// Convert turns the results of every run of a SARIF log into a GitLab report.
+// Results suppressed in the SARIF log itself are left out.
+func (o SARIFInputOptions) Convert(b []byte) (report.Report, error) {
+	r := report.NewReport()
+
+	var sarif SARIFLog
+	if err := json.Unmarshal(b, &sarif); err != nil {
+		return r, err
+	}
+
+	for i, run := range sarif.Runs {
+		driver := run.Tool.Driver
+		if i == 0 {
+			r.Scan = report.Scan{
+				Scanner: report.ScannerDetails{
+					ID:      scannerID(driver.Name),
+					Name:    driver.Name,
+					URL:     driver.InformationURI,
+					Vendor:  report.Vendor{Name: driver.Name},
+					Version: driver.Version,
+				},
+				Type:   o.Category,
+				Status: report.StatusSuccess,
+			}
+		}
+
+		rules := make(map[string]SARIFRule)
+		for _, component := range append([]SARIFDriver{driver}, run.Tool.Extensions...) {
+			for _, rule := range component.Rules {
+				rules[rule.ID] = rule
+			}
+		}
+
+		for _, result := range run.Results {
+			if len(result.Suppressions) > 0 {
+				continue
+			}
+
+			var rule SARIFRule
+			if result.RuleIndex != nil && *result.RuleIndex >= 0 && *result.RuleIndex < len(driver.Rules) {
+				rule = driver.Rules[*result.RuleIndex]
+			} else {
+				rule = rules[result.RuleID]
+			}
+			ruleID := result.RuleID
+			if ruleID == "" {
+				ruleID = rule.ID
+			}
+
+			v, err := o.vulnerability(driver, rule, ruleID, result)
+			if err != nil {
+				return r, err
+			}
+			r.Vulnerabilities = append(r.Vulnerabilities, v)
+		}
+	}
+
+	return r, nil
+}
+
+func (o SARIFInputOptions) vulnerability(driver SARIFDriver, rule SARIFRule, ruleID string, result SARIFResult) (report.Vulnerability, error) {
+	v := report.Vulnerability{
+		Category:   o.Category,
+		Name:       ruleID,
+		Message:    result.Message.Text,
+		Severity:   o.severity(rule, result),
+		Confidence: sarifConfidence(rule),
+		Scanner:    report.Scanner{ID: scannerID(driver.Name), Name: driver.Name},
+	}
+	if rule.ShortDescription != nil && rule.ShortDescription.Text != "" {
+		v.Name = rule.ShortDescription.Text
+	} else if rule.Name != "" {
+		v.Name = rule.Name
+	}
+	if rule.FullDescription != nil {
+		v.Description = rule.FullDescription.Text
+	}
+	if rule.Help != nil {
+		v.Solution = rule.Help.Text
+	}
+	if rule.HelpURI != "" {
+		v.Links = report.NewLinks(rule.HelpURI)
+	}
+
+	if len(result.Locations) > 0 {
+		loc := result.Locations[0]
+		if pl := loc.PhysicalLocation; pl != nil {
+			file, err := sarifPath(pl.ArtifactLocation.URI)
+			if err != nil {
+				return v, err
+			}
+			v.Location.File = file
+			if pl.Region != nil {
+				v.Location.LineStart = pl.Region.StartLine
+				v.Location.LineEnd = pl.Region.EndLine
+				if pl.Region.Snippet != nil {
+					v.RawSourceCodeExtract = pl.Region.Snippet.Text
+				}
+			}
+		}
+		for _, ll := range loc.LogicalLocations {
+			if ll.Kind == "function" || ll.Kind == "member" {
+				v.Location.Method = ll.Name
+			}
+		}
+	}
+
+	if ruleID != "" {
+		v.Identifiers = append(v.Identifiers, report.Identifier{
+			Type:  report.IdentifierType(scannerID(driver.Name) + "_rule_id"),
+			Name:  fmt.Sprintf("%s rule ID %s", driver.Name, ruleID),
+			Value: ruleID,
+			URL:   rule.HelpURI,
+		})
+	}
+	for _, cwe := range sarifCWEs(rule) {
+		v.Identifiers = append(v.Identifiers, report.CWEIdentifier(cwe))
+	}
+
+	v.CompareKey = strings.Join([]string{v.Location.File, ruleID, strconv.Itoa(v.Location.LineStart)}, ":")
+
+	return v, nil
+}
+
+// severity maps a result onto a severity. Unless disabled, the CVSS-like "security-severity" rule property
+// set by CodeQL and others takes precedence over the configured level mapping.
+func (o SARIFInputOptions) severity(rule SARIFRule, result SARIFResult) report.SeverityLevel {
+	if score, ok := sarifSecuritySeverity(rule.Properties); ok && o.SecuritySeverity {
+		switch {
+		case score >= 9:
+			return report.SeverityLevelCritical
+		case score >= 7:
+			return report.SeverityLevelHigh
+		case score >= 4:
+			return report.SeverityLevelMedium
+		case score > 0:
+			return report.SeverityLevelLow
+		}
+		return report.SeverityLevelInfo
+	}
+
+	level := result.Level
+	if level == "" && rule.DefaultConfiguration != nil {
+		level = rule.DefaultConfiguration.Level
+	}
+	if level == "" {
+		level = "warning" // default level of the SARIF specification
+	}
+	if s, ok := o.Levels[level]; ok {
+		return s
+	}
+	return report.SeverityLevelUnknown
+}
+
+func sarifSecuritySeverity(properties map[string]interface{}) (float64, bool) {
+	switch score := properties["security-severity"].(type) {
+	case string:
+		f, err := strconv.ParseFloat(score, 64)
+		return f, err == nil
+	case float64:
+		return score, true
+	}
+	return 0, false
+}
+
+// sarifConfidence maps the "precision" rule property onto a confidence level
+func sarifConfidence(rule SARIFRule) report.ConfidenceLevel {
+	precision, _ := rule.Properties["precision"].(string)
+	switch strings.ToLower(precision) {
+	case "very-high":
+		return report.ConfidenceLevelConfirmed
+	case "high":
+		return report.ConfidenceLevelHigh
+	case "medium":
+		return report.ConfidenceLevelMedium
+	case "low":
+		return report.ConfidenceLevelLow
+	}
+	return report.ConfidenceLevelUnknown
+}
+
+// sarifCWEs extracts the CWE ids from the tags of a rule
+func sarifCWEs(rule SARIFRule) []int {
+	tags, _ := rule.Properties["tags"].([]interface{})
+
+	seen := make(map[int]bool)
+	var cwes []int
+	for _, tag := range tags {
+		s, _ := tag.(string)
+		if m := sarifCWETag.FindStringSubmatch(s); m != nil {
+			if id, err := strconv.Atoi(m[1]); err == nil && !seen[id] {
+				seen[id] = true
+				cwes = append(cwes, id)
+			}
+		}
+	}
+	sort.Ints(cwes)
+	return cwes
+}
+
+// sarifPath converts an artifact URI into a path relative to the repository.
+// Absolute paths within the working directory are made relative to it.
+func sarifPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid artifact uri %q: %w", uri, err)
+	}
+	path := u.Path
+	if u.Scheme == "" && u.Opaque != "" {
+		path = u.Opaque
+	}
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = filepath.ToSlash(rel)
+			}
+		}
+	}
+	return strings.TrimPrefix(path, "./"), nil
+}
+
+// scannerID turns a tool name into a snake_case scanner id
+func scannerID(name string) string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// sarifLevelFlag is a flag.Value overriding the severity of SARIF levels,
+// given as a comma separated list of level=severity pairs
+type sarifLevelFlag map[string]report.SeverityLevel
+
+func (f sarifLevelFlag) String() string {
+	var pairs []string
+	for level, severity := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", level, severity))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f sarifLevelFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid SARIF level mapping %q, expected level=severity", pair)
+		}
+		level := strings.ToLower(strings.TrimSpace(parts[0]))
+		switch level {
+		case "error", "warning", "note", "none":
+		default:
+			return fmt.Errorf("invalid SARIF level %q, expected error, warning, note or none", parts[0])
+		}
+		severity, err := parseSeverity(parts[1])
+		if err != nil {
+			return err
+		}
+		f[level] = severity
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// GitleaksFinding is an entry of a Gitleaks JSON report
+type GitleaksFinding struct {
+	Description string   `json:"Description"`
+	StartLine   int      `json:"StartLine"`
+	EndLine     int      `json:"EndLine"`
+	Secret      string   `json:"Secret"`
+	File        string   `json:"File"`
+	Commit      string   `json:"Commit"`
+	Author      string   `json:"Author"`
+	Email       string   `json:"Email"`
+	Date        string   `json:"Date"`
+	Message     string   `json:"Message"`
+	Tags        []string `json:"Tags"`
+	RuleID      string   `json:"RuleID"`
+	Fingerprint string   `json:"Fingerprint"`
+}
+
+// TruffleHogFinding is a line of the TruffleHog JSON output
+type TruffleHogFinding struct {
+	SourceMetadata struct {
+		Data map[string]TruffleHogSource `json:"Data"`
+	} `json:"SourceMetadata"`
+	SourceName   string `json:"SourceName"`
+	DetectorName string `json:"DetectorName"`
+	Verified     bool   `json:"Verified"`
+	Raw          string `json:"Raw"`
+}
+
+// TruffleHogSource is the location of a TruffleHog finding, keyed by source type (Git, Filesystem, ...)
+type TruffleHogSource struct {
+	Commit     string `json:"commit"`
+	File       string `json:"file"`
+	Email      string `json:"email"`
+	Repository string `json:"repository"`
+	Timestamp  string `json:"timestamp"`
+	Line       int    `json:"line"`
+}
+
+// convertGitleaks turns a Gitleaks JSON report into a secret detection report
+func convertGitleaks(b []byte) (report.Report, error) {
+	r := secretDetectionReport("gitleaks", "Gitleaks")
+
+	var findings []GitleaksFinding
+	if err := json.Unmarshal(b, &findings); err != nil {
+		return r, err
+	}
+
+	for _, f := range findings {
+		v := report.Vulnerability{
+			Category:             report.CategorySecretDetection,
+			Name:                 f.Description,
+			Message:              f.Description + " detected; please remove and revoke it if this is a leak.",
+			Description:          f.Description,
+			CompareKey:           f.Fingerprint,
+			Severity:             report.SeverityLevelCritical,
+			Confidence:           report.ConfidenceLevelUnknown,
+			RawSourceCodeExtract: f.Secret,
+			Scanner:              report.Scanner{ID: "gitleaks", Name: "Gitleaks"},
+			Location: report.Location{
+				File:      f.File,
+				LineStart: f.StartLine,
+				LineEnd:   f.EndLine,
+				Commit:    secretCommit(f.Commit, f.Author, f.Email, f.Date, f.Message),
+			},
+			Identifiers: []report.Identifier{{
+				Type:  "gitleaks_rule_id",
+				Name:  "Gitleaks rule ID " + f.RuleID,
+				Value: f.RuleID,
+			}},
+		}
+		if v.CompareKey == "" {
+			v.CompareKey = strings.Join([]string{f.Commit, f.File, f.RuleID, strconv.Itoa(f.StartLine)}, ":")
+		}
+		r.Vulnerabilities = append(r.Vulnerabilities, v)
+	}
+
+	return r, nil
+}
+
+// convertTruffleHog turns the JSON lines output of TruffleHog into a secret detection report.
+// Verified secrets are reported with a confirmed confidence.
+func convertTruffleHog(b []byte) (report.Report, error) {
+	r := secretDetectionReport("trufflehog", "TruffleHog")
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		var f TruffleHogFinding
+		if err := dec.Decode(&f); err == io.EOF {
+			break
+		} else if err != nil {
+			return r, err
+		}
+
+		var src TruffleHogSource
+		for _, data := range f.SourceMetadata.Data {
+			src = data
+			break
+		}
+
+		confidence := report.ConfidenceLevelUnknown
+		if f.Verified {
+			confidence = report.ConfidenceLevelConfirmed
+		}
+
+		v := report.Vulnerability{
+			Category:             report.CategorySecretDetection,
+			Name:                 f.DetectorName,
+			Message:              fmt.Sprintf("%s secret detected; please remove and revoke it if this is a leak.", f.DetectorName),
+			Description:          fmt.Sprintf("%s secret found by %s", f.DetectorName, f.SourceName),
+			CompareKey:           strings.Join([]string{src.Commit, src.File, f.DetectorName, strconv.Itoa(src.Line)}, ":"),
+			Severity:             report.SeverityLevelCritical,
+			Confidence:           confidence,
+			RawSourceCodeExtract: f.Raw,
+			Scanner:              report.Scanner{ID: "trufflehog", Name: "TruffleHog"},
+			Location: report.Location{
+				File:      src.File,
+				LineStart: src.Line,
+				LineEnd:   src.Line,
+				Commit:    secretCommit(src.Commit, "", src.Email, src.Timestamp, ""),
+			},
+			Identifiers: []report.Identifier{{
+				Type:  "trufflehog_detector",
+				Name:  "TruffleHog detector " + f.DetectorName,
+				Value: f.DetectorName,
+			}},
+		}
+		r.Vulnerabilities = append(r.Vulnerabilities, v)
+	}
+
+	return r, nil
+}
+
+func secretDetectionReport(id, name string) report.Report {
+	r := report.NewReport()
+	r.Scan = report.Scan{
+		Scanner: report.ScannerDetails{ID: id, Name: name, Vendor: report.Vendor{Name: name}},
+		Type:    report.CategorySecretDetection,
+		Status:  report.StatusSuccess,
+	}
+	return r
+}
+
+// secretCommit builds the commit of a secret finding, nil when the scan did not run on git history
+func secretCommit(sha, author, email, date, message string) *report.Commit {
+	if sha == "" {
+		return nil
+	}
+	switch {
+	case author != "" && email != "":
+		author = fmt.Sprintf("%s <%s>", author, email)
+	case author == "":
+		author = email
+	}
+	return &report.Commit{Author: author, Date: date, Message: message, Sha: sha}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// finding holds the fields of a converted vulnerability checked by the adapter tests
+type finding struct {
+	Name        string
+	Severity    report.SeverityLevel
+	Confidence  report.ConfidenceLevel
+	File        string
+	Line        int
+	Image       string
+	OS          string
+	Package     string
+	CompareKey  string
+	Identifiers []string // Identifiers are formatted as type:value
+}
+
+func findingOf(v report.Vulnerability) finding {
+	f := finding{
+		Name:       v.Name,
+		Severity:   v.Severity,
+		Confidence: v.Confidence,
+		File:       v.Location.File,
+		Line:       v.Location.LineStart,
+		Image:      v.Location.Image,
+		OS:         v.Location.OperatingSystem,
+		CompareKey: v.CompareKey,
+	}
+	if v.Location.Dependency != nil {
+		f.Package = v.Location.Dependency.Package.Name + "@" + v.Location.Dependency.Version
+	}
+	for _, id := range v.Identifiers {
+		f.Identifiers = append(f.Identifiers, string(id.Type)+":"+id.Value)
+	}
+	return f
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Format
+		wantErr string
+	}{
+		{"gitlab", secretReport("a.env"), FormatGitLab, ""},
+		{"gitlab without findings", `{"version":"15.0.0","vulnerabilities":[]}`, FormatGitLab, ""},
+		{"sarif", "testdata/codeql.sarif", FormatSARIF, ""},
+		{"sarif without schema", `{"version":"2.1.0","runs":[]}`, FormatSARIF, ""},
+		{"gitleaks", "testdata/gitleaks.json", FormatGitleaks, ""},
+		{"gitleaks without findings", "[]", FormatGitleaks, ""},
+		{"trufflehog", "testdata/trufflehog.json", FormatTruffleHog, ""},
+		{"trivy", "testdata/trivy.json", FormatTrivy, ""},
+		{"trivy without results", "testdata/trivy-clean.json", FormatTrivy, ""},
+		{"grype", "testdata/grype.json", FormatGrype, ""},
+		{"empty", " \n", "", "empty report"},
+		{"unknown array", `[{"id":1}]`, "", "unknown report format"},
+		{"trailing data", `{"vulnerabilities":[]} {}`, "", "unexpected data after the report"},
+		{"invalid", `{"vulnerabilities":`, "", "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := []byte(tt.content)
+			if strings.HasPrefix(tt.content, "testdata/") {
+				var err error
+				if b, err = os.ReadFile(tt.content); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := detectFormat(b)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderLoad(t *testing.T) {
+	mapping, err := loadMapping("testdata/acme-lint.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader := NewLoader()
+	loader.Mappings = []*Mapping{mapping}
+
+	tests := []struct {
+		file     string
+		scanner  string
+		category report.Category
+		want     []finding
+	}{
+		{
+			file:     "codeql.sarif",
+			scanner:  "codeql",
+			category: report.CategorySast,
+			want: []finding{
+				{
+					Name:        "Database query built from user-controlled sources",
+					Severity:    report.SeverityLevelHigh,
+					Confidence:  report.ConfidenceLevelHigh,
+					File:        "internal/store/users.go",
+					Line:        42,
+					CompareKey:  "internal/store/users.go:go/sql-injection:42",
+					Identifiers: []string{"codeql_rule_id:go/sql-injection", "cwe:89"},
+				},
+				{
+					Name:        "Writable file handle closed without error handling",
+					Severity:    report.SeverityLevelLow,
+					Confidence:  report.ConfidenceLevelConfirmed,
+					File:        "cmd/export.go",
+					Line:        17,
+					CompareKey:  "cmd/export.go:go/unhandled-writable-file-close:17",
+					Identifiers: []string{"codeql_rule_id:go/unhandled-writable-file-close", "cwe:252"},
+				},
+			},
+		},
+		{
+			file:     "gitleaks.json",
+			scanner:  "gitleaks",
+			category: report.CategorySecretDetection,
+			want: []finding{{
+				Name:        "AWS Access Key",
+				Severity:    report.SeverityLevelCritical,
+				Confidence:  report.ConfidenceLevelUnknown,
+				File:        "deploy/terraform.tfvars",
+				Line:        12,
+				CompareKey:  "4f2a1c9e7b3d5a8f0e6c2b1a9d8e7f6a5b4c3d2e:deploy/terraform.tfvars:aws-access-token:12",
+				Identifiers: []string{"gitleaks_rule_id:aws-access-token"},
+			}},
+		},
+		{
+			file:     "trufflehog.json",
+			scanner:  "trufflehog",
+			category: report.CategorySecretDetection,
+			want: []finding{
+				{
+					Name:        "Slack",
+					Severity:    report.SeverityLevelCritical,
+					Confidence:  report.ConfidenceLevelConfirmed,
+					File:        "config/settings.py",
+					Line:        7,
+					CompareKey:  "9b1f3e2d4c5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c:config/settings.py:Slack:7",
+					Identifiers: []string{"trufflehog_detector:Slack"},
+				},
+				{
+					Name:        "Stripe",
+					Severity:    report.SeverityLevelCritical,
+					Confidence:  report.ConfidenceLevelUnknown,
+					File:        "scripts/seed.sh",
+					Line:        3,
+					CompareKey:  ":scripts/seed.sh:Stripe:3",
+					Identifiers: []string{"trufflehog_detector:Stripe"},
+				},
+			},
+		},
+		{
+			file:     "trivy.json",
+			scanner:  "trivy",
+			category: report.CategoryContainerScanning,
+			want: []finding{
+				{
+					Name:        "curl: heap based buffer overflow in the SOCKS5 proxy handshake",
+					Severity:    report.SeverityLevelCritical,
+					Confidence:  report.ConfidenceLevelUnknown,
+					Image:       "registry.example.com/acme/api:1.4.2",
+					OS:          "debian:12.2",
+					Package:     "curl@7.88.1-10+deb12u3",
+					CompareKey:  "debian:12.2:curl:CVE-2023-38545",
+					Identifiers: []string{"cve:CVE-2023-38545", "cwe:787"},
+				},
+				{
+					Name:        "It was found that apt-key in apt, all versions, do not correctly validate gpg keys with the master keyring, leading to a potential MITM attack.",
+					Severity:    report.SeverityLevelLow,
+					Confidence:  report.ConfidenceLevelUnknown,
+					Image:       "registry.example.com/acme/api:1.4.2",
+					OS:          "debian:12.2",
+					Package:     "apt@2.6.1",
+					CompareKey:  "debian:12.2:apt:CVE-2011-3374",
+					Identifiers: []string{"cve:CVE-2011-3374", "cwe:347"},
+				},
+			},
+		},
+		{
+			file:     "trivy-clean.json",
+			scanner:  "trivy",
+			category: report.CategoryContainerScanning,
+		},
+		{
+			file:     "grype.json",
+			scanner:  "grype",
+			category: report.CategoryContainerScanning,
+			want: []finding{
+				{
+					Name:        "GHSA-jfh8-c2jp-5v3q",
+					Severity:    report.SeverityLevelCritical,
+					Confidence:  report.ConfidenceLevelUnknown,
+					Image:       "registry.example.com/acme/billing:3.1.0",
+					OS:          "debian:11",
+					Package:     "log4j-core@2.14.1",
+					CompareKey:  "debian:11:log4j-core:GHSA-jfh8-c2jp-5v3q",
+					Identifiers: []string{"grype_id:GHSA-jfh8-c2jp-5v3q"},
+				},
+				{
+					Name:        "CVE-2022-3715",
+					Severity:    report.SeverityLevelInfo,
+					Confidence:  report.ConfidenceLevelUnknown,
+					Image:       "registry.example.com/acme/billing:3.1.0",
+					OS:          "debian:11",
+					Package:     "bash@5.1-2+deb11u1",
+					CompareKey:  "debian:11:bash:CVE-2022-3715",
+					Identifiers: []string{"cve:CVE-2022-3715"},
+				},
+			},
+		},
+		{
+			file:     "acme-lint.json",
+			scanner:  "acme_lint",
+			category: report.CategorySast,
+			want: []finding{
+				{
+					Name:        "Hardcoded temporary directory",
+					Severity:    report.SeverityLevelCritical,
+					Confidence:  report.ConfidenceLevelUnknown,
+					File:        "src/tmp.py",
+					Line:        14,
+					CompareKey:  "src/tmp.py:14:Hardcoded temporary directory:ACME001",
+					Identifiers: []string{"acme_check:ACME001"},
+				},
+				{
+					Name:        "Use of eval",
+					Severity:    report.SeverityLevelLow,
+					Confidence:  report.ConfidenceLevelUnknown,
+					File:        "src/run.py",
+					Line:        3,
+					CompareKey:  "src/run.py:3:Use of eval:ACME007",
+					Identifiers: []string{"acme_check:ACME007"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			r, err := loader.Load(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Scan.Scanner.ID != tt.scanner || r.Scan.Type != tt.category {
+				t.Errorf("scan: got %s %s, want %s %s", r.Scan.Scanner.ID, r.Scan.Type, tt.scanner, tt.category)
+			}
+
+			var got []finding
+			for _, v := range r.Vulnerabilities {
+				if v.Category != tt.category {
+					t.Errorf("%s: category %s, want %s", v.Name, v.Category, tt.category)
+				}
+				if v.Scanner.ID != tt.scanner {
+					t.Errorf("%s: scanner %s, want %s", v.Name, v.Scanner.ID, tt.scanner)
+				}
+				got = append(got, findingOf(v))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSARIFSeverity(t *testing.T) {
+	levels := map[string]report.SeverityLevel{"error": report.SeverityLevelCritical, "note": report.SeverityLevelInfo}
+
+	tests := []struct {
+		name             string
+		score            interface{}
+		ruleLevel        string
+		resultLevel      string
+		securitySeverity bool
+		want             report.SeverityLevel
+	}{
+		{"critical score", "9.1", "error", "", true, report.SeverityLevelCritical},
+		{"high score", "7.5", "note", "", true, report.SeverityLevelHigh},
+		{"medium score", 4.0, "", "", true, report.SeverityLevelMedium},
+		{"low score", "0.1", "", "", true, report.SeverityLevelLow},
+		{"zero score", "0", "error", "", true, report.SeverityLevelInfo},
+		{"score ignored", "7.5", "note", "", false, report.SeverityLevelInfo},
+		{"invalid score", "high", "error", "", true, report.SeverityLevelCritical},
+		{"result level", nil, "note", "error", true, report.SeverityLevelCritical},
+		{"rule level", nil, "note", "", true, report.SeverityLevelInfo},
+		{"default level unmapped", nil, "", "", true, report.SeverityLevelUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := SARIFRule{ID: "r", Properties: map[string]interface{}{}}
+			if tt.score != nil {
+				rule.Properties["security-severity"] = tt.score
+			}
+			if tt.ruleLevel != "" {
+				rule.DefaultConfiguration = &SARIFConfiguration{Level: tt.ruleLevel}
+			}
+			o := SARIFInputOptions{Category: report.CategorySast, Levels: levels, SecuritySeverity: tt.securitySeverity}
+
+			if got := o.severity(rule, SARIFResult{Level: tt.resultLevel}); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSARIFPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"src/main.go", "src/main.go"},
+		{"./src/main.go", "src/main.go"},
+		{"src/a%20b.go", "src/a b.go"},
+		{"file://" + filepath.ToSlash(wd) + "/src/main.go", "src/main.go"},
+		{"file:///elsewhere/main.go", "/elsewhere/main.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := sarifPath(tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelector(t *testing.T) {
+	doc := map[string]interface{}{
+		"runs": []interface{}{
+			map[string]interface{}{"id": "a", "tags": []interface{}{"x", "y"}},
+			map[string]interface{}{"id": "b", "tags": []interface{}{"z"}},
+		},
+		"meta":    map[string]interface{}{"tool": "t", "version": "1"},
+		"odd key": true,
+	}
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr string
+	}{
+		{path: "$", want: []interface{}{doc}},
+		{path: "$.runs[0].id", want: []interface{}{"a"}},
+		{path: "runs[-1].id", want: []interface{}{"b"}},
+		{path: "$.runs[*].id", want: []interface{}{"a", "b"}},
+		{path: "$.runs.*.tags[*]", want: []interface{}{"x", "y", "z"}},
+		{path: "$.meta.*", want: []interface{}{"t", "1"}},
+		{path: "$['odd key']", want: []interface{}{true}},
+		{path: "$.runs[2].id"},
+		{path: "$.meta[0]"},
+		{path: "$.missing.id"},
+		{path: "", wantErr: "empty selector"},
+		{path: "$.runs..id", wantErr: "empty name"},
+		{path: "$.runs[0", wantErr: "missing ]"},
+		{path: "$.runs[first]", wantErr: `bad index "first"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			sel, err := compileSelector(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sel.Select(doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// Baseline holds the findings of a previous run, typically the default branch artifacts.
+// Findings are matched on their identifiers and location rather than on Vulnerability.ID(),
+// which hashes the whole vulnerability and changes whenever the message or line numbers change.
+type Baseline struct {
+	Findings []*Finding
+	keys     map[string]bool
+}
+
+// loadBaseline reads the baseline reports from a file, directory or glob pattern
+func loadBaseline(loader *Loader, input string) (*Baseline, error) {
+	files, err := discoverReports([]string{input}, loader.Patterns(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, readError(input, fmt.Errorf("no baseline reports found in %s", input))
+	}
+
+	var res Result
+	for _, f := range files {
+		r, err := loader.Load(f)
+		if err != nil {
+			return nil, err
+		}
+		res.Add(f, r)
+	}
+
+	b := &Baseline{Findings: res.Findings(), keys: make(map[string]bool)}
+	for _, f := range b.Findings {
+		for _, key := range fingerprints(f) {
+			b.keys[key] = true
+		}
+	}
+	return b, nil
+}
+
+// Contains is true when the finding was already present in the baseline
+func (b *Baseline) Contains(f *Finding) bool {
+	for _, key := range fingerprints(f) {
+		if b.keys[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// Fixed returns the baseline findings that are no longer reported
+func (b *Baseline) Fixed(current []*Finding) []*Finding {
+	keys := make(map[string]bool)
+	for _, f := range current {
+		for _, key := range fingerprints(f) {
+			keys[key] = true
+		}
+	}
+
+	var fixed []*Finding
+outer:
+	for _, f := range b.Findings {
+		for _, key := range fingerprints(f) {
+			if keys[key] {
+				continue outer
+			}
+		}
+		fixed = append(fixed, f)
+	}
+	return fixed
+}
+
+// fingerprints returns the matching keys of a finding, one per identifier.
+// Like report.Dedupe, CWE identifiers are ignored since they only classify the vulnerability.
+// Line numbers are left out so findings survive unrelated edits to the file.
+func fingerprints(f *Finding) []string {
+	loc := locationKey(f.Location)
+
+	var keys []string
+	for _, id := range f.Identifiers {
+		if id.Type == report.IdentifierTypeCWE {
+			continue
+		}
+		keys = append(keys, strings.Join([]string{string(f.Category), loc, string(id.Type), id.Value}, "|"))
+	}
+	if len(keys) == 0 {
+		keys = append(keys, strings.Join([]string{string(f.Category), loc, f.Name}, "|"))
+	}
+	return keys
+}
+
+// locationKey identifies a location independently of line numbers and image tags
+func locationKey(l report.Location) string {
+	parts := []string{l.File, l.Class, l.Method, imageRepository(l.Image), l.OperatingSystem, l.CrashType, l.CrashState}
+	if l.Dependency != nil {
+		parts = append(parts, l.Dependency.Package.Name)
+	}
+	return strings.Join(parts, ":")
+}
+
+// imageRepository strips the tag and digest of an image reference, which change with every branch or commit,
+// e.g. registry.example.com:5000/group/app:feature-1@sha256:... yields registry.example.com:5000/group/app
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// secretReport is a secret detection report with a finding per file of the given names
+func secretReport(files ...string) string {
+	vulnerabilities := ""
+	for i, file := range files {
+		if i > 0 {
+			vulnerabilities += ","
+		}
+		vulnerabilities += `{"id":"` + file + `","category":"secret_detection","name":"AWS key","severity":"Critical",` +
+			`"cve":"` + file + `","scanner":{"id":"gitleaks","name":"Gitleaks"},"location":{"file":"` + file + `","start_line":1},` +
+			`"identifiers":[{"type":"gitleaks_rule_id","name":"AWS","value":"aws-access-token"}]}`
+	}
+	return `{"version":"15.0.0","vulnerabilities":[` + vulnerabilities + `],"scan":{"type":"secret_detection","status":"success"}}`
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "gl-secret-detection-report.json"), secretReport("kept.env", "added.env"))
+	writeFile(t, filepath.Join(dir, "baseline", "gl-secret-detection-report.json"), secretReport("kept.env", "fixed.env"))
+
+	loader := NewLoader()
+	baseline, err := loadBaseline(loader, filepath.Join(dir, "baseline"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := discoverReports([]string{dir}, loader.Patterns(), []string{filepath.Join(dir, "baseline")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "gl-secret-detection-report.json")}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("discovered %v, want %v", files, want)
+	}
+
+	var res Result
+	for _, f := range files {
+		r, err := loader.Load(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Add(f, r)
+	}
+
+	var added, existing, fixed []string
+	for _, f := range res.Findings() {
+		if baseline.Contains(f) {
+			existing = append(existing, f.Location.File)
+		} else {
+			added = append(added, f.Location.File)
+		}
+	}
+	for _, f := range baseline.Fixed(res.Findings()) {
+		fixed = append(fixed, f.Location.File)
+	}
+	sort.Strings(added)
+	sort.Strings(existing)
+
+	if want := []string{"added.env"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("new: got %v, want %v", added, want)
+	}
+	if want := []string{"kept.env"}; !reflect.DeepEqual(existing, want) {
+		t.Errorf("existing: got %v, want %v", existing, want)
+	}
+	if want := []string{"fixed.env"}; !reflect.DeepEqual(fixed, want) {
+		t.Errorf("fixed: got %v, want %v", fixed, want)
+	}
+}
+
+func TestDiscoverReportsSkip(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"gl-sast-report.json",
+		"baseline/gl-sast-report.json",
+		"out/gl-sast-report.json",
+		"scan.sarif",
+		"gate.sarif",
+	} {
+		writeFile(t, filepath.Join(dir, name), "{}")
+	}
+
+	tests := []struct {
+		name string
+		skip []string
+		want []string
+	}{
+		{"nothing skipped", nil, []string{"baseline/gl-sast-report.json", "gate.sarif", "gl-sast-report.json", "out/gl-sast-report.json", "scan.sarif"}},
+		{"directories", []string{filepath.Join(dir, "baseline"), filepath.Join(dir, "out") + "/"}, []string{"gate.sarif", "gl-sast-report.json", "scan.sarif"}},
+		{"file", []string{filepath.Join(dir, "gate.sarif")}, []string{"baseline/gl-sast-report.json", "gl-sast-report.json", "out/gl-sast-report.json", "scan.sarif"}},
+		{"glob", []string{filepath.Join(dir, "*", "gl-*-report.json")}, []string{"gate.sarif", "gl-sast-report.json", "scan.sarif"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := discoverReports([]string{dir}, discoveryPatterns, tt.skip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range files {
+				rel, _ := filepath.Rel(dir, f)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"", ""},
+		{"alpine", "alpine"},
+		{"alpine:3.18", "alpine"},
+		{"registry.example.com/group/app:feature-1", "registry.example.com/group/app"},
+		{"registry.example.com:5000/group/app", "registry.example.com:5000/group/app"},
+		{"registry.example.com:5000/group/app:1a2b3c4d", "registry.example.com:5000/group/app"},
+		{"registry.example.com:5000/group/app@sha256:0123abcd", "registry.example.com:5000/group/app"},
+		{"registry.example.com:5000/group/app:main@sha256:0123abcd", "registry.example.com:5000/group/app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := imageRepository(tt.image); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
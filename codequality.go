@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// codeQualitySeverities are the severities accepted by GitLab Code Quality reports
+var codeQualitySeverities = []string{"info", "minor", "major", "critical", "blocker"}
+
+// defaultCodeQualitySeverities maps report severities onto Code Quality severities
+var defaultCodeQualitySeverities = map[report.SeverityLevel]string{
+	report.SeverityLevelCritical: "blocker",
+	report.SeverityLevelHigh:     "critical",
+	report.SeverityLevelMedium:   "major",
+	report.SeverityLevelLow:      "minor",
+	report.SeverityLevelUnknown:  "info",
+	report.SeverityLevelInfo:     "info",
+}
+
+// CodeQualityIssue is an entry of a GitLab Code Quality report
+type CodeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Categories  []string            `json:"categories"`
+	Location    CodeQualityLocation `json:"location"`
+}
+
+// CodeQualityLocation is the file and line a Code Quality issue is reported on
+type CodeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines CodeQualityLines `json:"lines"`
+}
+
+// CodeQualityLines is the line range of a Code Quality issue
+type CodeQualityLines struct {
+	Begin int `json:"begin"`
+	End   int `json:"end,omitempty"`
+}
+
+// writeCodeQuality writes the failing and warning findings as a GitLab Code Quality report.
+// Findings without a file, such as container scanning ones, cannot be annotated and are left out.
+func writeCodeQuality(filename string, res *Result, redactor Redactor, severities map[report.SeverityLevel]string) error {
+	findings := res.Findings()
+	sortFindings(findings)
+
+	issues := []CodeQualityIssue{}
+	seen := make(map[string]int)
+	for _, f := range findings {
+		if f.Decision != DecisionFail && f.Decision != DecisionWarn {
+			continue
+		}
+		if f.Location.File == "" {
+			continue
+		}
+
+		v := redactor.Vulnerability(f.Vulnerability)
+
+		severity, ok := severities[severityOf(v)]
+		if !ok {
+			severity = defaultCodeQualitySeverities[severityOf(v)]
+		}
+
+		description := fmt.Sprintf("[%s] %s", v.Scanner.Name, v.Name)
+		if v.Message != "" && v.Message != v.Name {
+			description += ": " + v.Message
+		}
+
+		begin := v.Location.LineStart
+		if begin == 0 {
+			begin = 1
+		}
+
+		// the fingerprint is stable across line changes, duplicates are numbered to keep it unique
+		key := strings.Join(fingerprints(f), ",")
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key = fmt.Sprintf("%s#%d", key, n)
+		}
+
+		issues = append(issues, CodeQualityIssue{
+			Description: description,
+			CheckName:   primaryIdentifier(v),
+			Fingerprint: fmt.Sprintf("%x", sha256.Sum256([]byte(key))),
+			Severity:    severity,
+			Categories:  []string{"Security"},
+			Location: CodeQualityLocation{
+				Path:  strings.TrimPrefix(v.Location.File, "./"),
+				Lines: CodeQualityLines{Begin: begin, End: v.Location.LineEnd},
+			},
+		})
+	}
+
+	b, err := json.MarshalIndent(issues, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0644)
+}
+
+// codeQualitySeverityFlag is a flag.Value overriding the Code Quality severity per report severity,
+// given as a comma separated list of severity=codequality pairs
+type codeQualitySeverityFlag map[report.SeverityLevel]string
+
+func (f codeQualitySeverityFlag) String() string {
+	var pairs []string
+	for severity, cq := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", severity, cq))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f codeQualitySeverityFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid code quality severity %q, expected severity=codequality", pair)
+		}
+		severity, err := parseSeverity(parts[0])
+		if err != nil {
+			return err
+		}
+		cq := strings.ToLower(strings.TrimSpace(parts[1]))
+		if !containsString(codeQualitySeverities, cq) {
+			return fmt.Errorf("invalid code quality severity %q, expected one of %s", parts[1], strings.Join(codeQualitySeverities, ", "))
+		}
+		f[severity] = cq
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
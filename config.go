@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is the prefix of the environment variables setting flags, e.g. GATE_SEVERITY for -severity
+const envPrefix = "GATE_"
+
+// configReportsKey is the configuration key of the report paths given as arguments on the command line
+const configReportsKey = "reports"
+
+// defaultConfigPaths are the configuration files read when neither -config nor GATE_CONFIG is set
+var defaultConfigPaths = []string{
+	".gitlab/security-gate-config.yml",
+	".gitlab/security-gate-config.yaml",
+	".gitlab/security-gate-config.toml",
+	".gitlab/security-gate-config.json",
+}
+
+// repeatableFlags are the flags whose environment variable holds several whitespace separated values
+var repeatableFlags = map[string]bool{"mapping": true, "exclude": true, "rules": true}
+
+// Config is the configuration of the gate, layered from a configuration file,
+// GATE_* environment variables and command line flags, the last one winning.
+type Config struct {
+	Path    string            // Path is the configuration file read, if any
+	Reports []string          // Reports are the report paths, directories or globs to gate
+	Sources map[string]string // Sources tell where the value of each flag comes from
+
+	// Floor holds what the extended policies impose, which no layer may loosen
+	Floor floor
+}
+
+// loadConfig parses the command line flags of fs and sets the flags missing from it
+// from the environment or the configuration file. Keys of the file are flag names.
+func loadConfig(fs *flag.FlagSet, args []string, configPath *string) (*Config, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, configError("", err)
+	}
+
+	cfg := &Config{Sources: make(map[string]string)}
+	fs.Visit(func(f *flag.Flag) {
+		cfg.Sources[f.Name] = "flag"
+	})
+
+	cfg.Path = *configPath
+	if cfg.Path == "" {
+		cfg.Path = os.Getenv(envName("config"))
+	}
+	required := cfg.Path != ""
+	if !required {
+		for _, path := range defaultConfigPaths {
+			if _, err := os.Stat(path); err == nil {
+				cfg.Path = path
+				break
+			}
+		}
+	}
+
+	values := make(map[string]interface{})
+	if cfg.Path != "" {
+		policyDir := fs.Lookup("policy-dir").Value.String()
+		if policyDir == "" {
+			policyDir = os.Getenv(envName("policy-dir"))
+		}
+
+		var err error
+		if values, cfg.Floor, err = loadConfigFile(cfg.Path, policyDir, nil); err != nil {
+			return nil, configError(cfg.Path, err)
+		}
+	}
+	for name := range values {
+		if name != configReportsKey && fs.Lookup(name) == nil {
+			return nil, configError(cfg.Path, fmt.Errorf("%s: unknown key %q", cfg.Path, name))
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || cfg.Sources[f.Name] != "" {
+			return
+		}
+		if env, ok := os.LookupEnv(envName(f.Name)); ok {
+			list := []string{env}
+			if repeatableFlags[f.Name] {
+				list = strings.Fields(env)
+			}
+			if err = setFlag(fs, f.Name, list); err != nil {
+				err = configError("", fmt.Errorf("%s: %w", envName(f.Name), err))
+				return
+			}
+			cfg.Sources[f.Name] = "env " + envName(f.Name)
+			return
+		}
+		if value, ok := values[f.Name]; ok {
+			var list []string
+			if list, err = configValues(value); err == nil {
+				err = setFlag(fs, f.Name, list)
+			}
+			if err != nil {
+				err = configError(cfg.Path, fmt.Errorf("%s: %s: %w", cfg.Path, f.Name, err))
+				return
+			}
+			cfg.Sources[f.Name] = cfg.Path
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Reports = fs.Args()
+	if len(cfg.Reports) > 0 {
+		return cfg, nil
+	}
+	if env, ok := os.LookupEnv(envName(configReportsKey)); ok {
+		cfg.Reports = strings.Fields(env)
+	} else if value, ok := values[configReportsKey]; ok {
+		if cfg.Reports, err = configValues(value); err != nil {
+			return nil, configError(cfg.Path, fmt.Errorf("%s: %s: %w", cfg.Path, configReportsKey, err))
+		}
+	}
+	if len(cfg.Reports) == 0 {
+		cfg.Reports = []string{"."}
+	}
+	return cfg, nil
+}
+
+// flagArg returns the value of a flag on the command line without parsing it,
+// for the flags needed after parsing failed before reaching them
+func flagArg(args []string, name string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if strings.HasPrefix(arg, name+"=") {
+			return strings.TrimPrefix(arg, name+"=")
+		}
+		if arg == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// Log prints the effective configuration at debug level
+func (cfg *Config) Log(fs *flag.FlagSet) {
+	if cfg.Path != "" {
+		log.Debugf("Configuration file %s", cfg.Path)
+	}
+	log.Debugf("Effective configuration:")
+	fs.VisitAll(func(f *flag.Flag) {
+		source := cfg.Sources[f.Name]
+		if source == "" {
+			source = "default"
+		}
+		log.Debugf("  %s = %q (%s)", f.Name, f.Value.String(), source)
+	})
+	log.Debugf("  %s = %q", configReportsKey, cfg.Reports)
+}
+
+// Extended tells whether the configuration extends other policies
+func (cfg *Config) Extended() bool {
+	return len(cfg.Floor.policies) > 0
+}
+
+// Enforce returns an error when the policy is looser than the extended policies
+// or when the environment or the command line set a key only the extended policies may set.
+// It keeps the rules other than those of the extended policies from going below their thresholds.
+func (cfg *Config) Enforce(p *Policy) error {
+	if cfg.Extended() {
+		for _, key := range lockedKeys {
+			if source := cfg.Sources[key]; source != "" && source != cfg.Path {
+				return configError(cfg.Path, fmt.Errorf("%s cannot be set by %s when extending another policy", key, source))
+			}
+		}
+	}
+	if err := cfg.Floor.check(policyThresholds(p)); err != nil {
+		return configError(cfg.Path, err)
+	}
+
+	// the rules of the extended policies come first, even when the environment or the command line set others
+	var rules []*Rule
+	var files []string
+	for _, path := range cfg.Floor.rules {
+		loaded := false
+		for _, file := range p.RuleFiles {
+			loaded = loaded || filepath.Clean(file) == filepath.Clean(path)
+		}
+		if loaded {
+			continue
+		}
+		extended, err := loadRules(path)
+		if err != nil {
+			return configError(path, err)
+		}
+		rules = append(rules, extended...)
+		files = append(files, path)
+	}
+	p.Rules = append(rules, p.Rules...)
+	p.RuleFiles = append(files, p.RuleFiles...)
+
+	p.Floor = cfg.Floor.thresholds
+	for _, r := range p.Rules {
+		for _, path := range cfg.Floor.rules {
+			if filepath.Clean(path) == filepath.Clean(r.file) {
+				r.extended = true
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeConfigKey returns the flag name of a configuration key, which may use underscores
+func normalizeConfigKey(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// envName returns the environment variable of a flag
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readConfigFile decodes a YAML, TOML or JSON configuration file according to its extension
+func readConfigFile(path string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(b, &m)
+	case ".toml":
+		var tree *toml.Tree
+		if tree, err = toml.LoadBytes(b); err == nil {
+			m = tree.ToMap()
+		}
+	case ".json":
+		err = json.Unmarshal(b, &m)
+	default:
+		return nil, fmt.Errorf("%s: unsupported configuration format, expected .yml, .yaml, .toml or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// configValues converts a configuration value into flag values. A list sets the flag once per element,
+// a map once per key=value pair.
+func configValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			s, err := configScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var list []string
+		for _, k := range keys {
+			s, err := configScalar(v[k])
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, k+"="+s)
+		}
+		return list, nil
+	}
+	s, err := configScalar(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func configScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", errors.New("missing value")
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+func setFlag(fs *flag.FlagSet, name string, values []string) error {
+	for _, value := range values {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
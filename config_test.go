@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// configFlags are the flags of a configuration test and the values they set
+type configFlags struct {
+	severity         report.SeverityLevel
+	exclusions       *Exclusions
+	warningsExitCode int
+	diffAware        bool
+}
+
+func newConfigFlagSet() (*flag.FlagSet, *configFlags, *string) {
+	values := &configFlags{severity: report.SeverityLevelCritical, exclusions: &Exclusions{}}
+
+	fs := flag.NewFlagSet("gate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(severityFlag{&values.severity}, "severity", "")
+	fs.Var(excludeFlag{values.exclusions}, "exclude", "")
+	fs.IntVar(&values.warningsExitCode, "warnings-exit-code", 0, "")
+	fs.BoolVar(&values.diffAware, "diff-aware", false, "")
+	configPath := fs.String("config", "", "")
+	fs.String("policy-dir", "", "")
+	return fs, values, configPath
+}
+
+// setConfigEnv sets the given GATE_* variables for the test, unsetting the other ones the flags read
+func setConfigEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	fs, _, _ := newConfigFlagSet()
+	names := []string{envName(configReportsKey)}
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, envName(f.Name))
+	})
+	for _, name := range names {
+		t.Setenv(name, env[name])
+		if _, ok := env[name]; !ok {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "gate.yml")
+	writeFile(t, config, "severity: medium\nexclude: [vendor/**, test/**]\nwarnings_exit_code: 3\nreports: [a.json, b.json]\n")
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		severity    report.SeverityLevel
+		source      string
+		exclude     []string
+		reports     []string
+		exitCode    int
+		exitSource  string
+		diffAware   bool
+		diffSources string
+	}{
+		{
+			name:     "file",
+			severity: report.SeverityLevelMedium, source: config,
+			exclude: []string{"vendor/**", "test/**"}, reports: []string{"a.json", "b.json"},
+			exitCode: 3, exitSource: config,
+		},
+		{
+			name:     "environment over file",
+			env:      map[string]string{"GATE_SEVERITY": "high", "GATE_EXCLUDE": "docs/** build/**", "GATE_REPORTS": "c.json", "GATE_DIFF_AWARE": "true"},
+			severity: report.SeverityLevelHigh, source: "env GATE_SEVERITY",
+			exclude: []string{"docs/**", "build/**"}, reports: []string{"c.json"},
+			exitCode: 3, exitSource: config,
+			diffAware: true, diffSources: "env GATE_DIFF_AWARE",
+		},
+		{
+			name:     "flag over environment",
+			env:      map[string]string{"GATE_SEVERITY": "high", "GATE_REPORTS": "c.json"},
+			args:     []string{"-severity", "low", "-warnings-exit-code", "0", "d.json"},
+			severity: report.SeverityLevelLow, source: "flag",
+			exclude: []string{"vendor/**", "test/**"}, reports: []string{"d.json"},
+			exitCode: 0, exitSource: "flag",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfigEnv(t, tt.env)
+			fs, values, configPath := newConfigFlagSet()
+
+			cfg, err := loadConfig(fs, append([]string{"-config", config}, tt.args...), configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if values.severity != tt.severity || cfg.Sources["severity"] != tt.source {
+				t.Errorf("severity: got %s from %q, want %s from %q", values.severity, cfg.Sources["severity"], tt.severity, tt.source)
+			}
+			if !reflect.DeepEqual(values.exclusions.Patterns, tt.exclude) {
+				t.Errorf("exclude: got %v, want %v", values.exclusions.Patterns, tt.exclude)
+			}
+			if values.warningsExitCode != tt.exitCode || cfg.Sources["warnings-exit-code"] != tt.exitSource {
+				t.Errorf("warnings-exit-code: got %d from %q, want %d from %q", values.warningsExitCode, cfg.Sources["warnings-exit-code"], tt.exitCode, tt.exitSource)
+			}
+			if values.diffAware != tt.diffAware || cfg.Sources["diff-aware"] != tt.diffSources {
+				t.Errorf("diff-aware: got %v from %q, want %v from %q", values.diffAware, cfg.Sources["diff-aware"], tt.diffAware, tt.diffSources)
+			}
+			if !reflect.DeepEqual(cfg.Reports, tt.reports) {
+				t.Errorf("reports: got %v, want %v", cfg.Reports, tt.reports)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"gate.yml", "severity: high\nwarnings-exit-code: 3\n"},
+		{"gate.yaml", "severity: high\nwarnings_exit_code: 3\n"},
+		{"gate.toml", "severity = \"high\"\nwarnings_exit_code = 3\n"},
+		{"gate.json", `{"severity": "high", "warnings-exit-code": 3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			setConfigEnv(t, nil)
+			config := filepath.Join(t.TempDir(), tt.file)
+			writeFile(t, config, tt.content)
+
+			fs, values, configPath := newConfigFlagSet()
+			if _, err := loadConfig(fs, []string{"-config", config}, configPath); err != nil {
+				t.Fatal(err)
+			}
+			if values.severity != report.SeverityLevelHigh || values.warningsExitCode != 3 {
+				t.Errorf("got severity %s and warnings-exit-code %d", values.severity, values.warningsExitCode)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown key", file: "gate.yml", content: "severty: high\n", wantErr: `unknown key "severty"`},
+		{name: "invalid value", file: "gate.yml", content: "severity: severe\n", wantErr: "severity"},
+		{name: "invalid list element", file: "gate.yml", content: "exclude: [{a: b}]\n", wantErr: "exclude"},
+		{name: "unsupported format", file: "gate.ini", content: "severity=high\n", wantErr: "unsupported configuration format"},
+		{name: "invalid environment", file: "gate.yml", env: map[string]string{"GATE_WARNINGS_EXIT_CODE": "three"}, wantErr: "GATE_WARNINGS_EXIT_CODE"},
+		{name: "invalid flag", file: "gate.yml", args: []string{"-severity", "severe"}, wantErr: "severe"},
+		{name: "missing file", wantErr: "no such file or directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfigEnv(t, tt.env)
+			config := filepath.Join(t.TempDir(), "missing.yml")
+			if tt.file != "" {
+				config = filepath.Join(filepath.Dir(config), tt.file)
+				writeFile(t, config, tt.content)
+			}
+
+			fs, _, configPath := newConfigFlagSet()
+			_, err := loadConfig(fs, append([]string{"-config", config}, tt.args...), configPath)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if code, _ := exitCodeOf(err); code != ExitConfig {
+				t.Errorf("exit code %s, want %s", code, ExitConfig)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiRed     = "\x1b[0;31m"
+	ansiBoldRed = "\x1b[1;31m"
+	ansiGreen   = "\x1b[0;32m"
+	ansiYellow  = "\x1b[0;33m"
+	ansiBlue    = "\x1b[0;34m"
+	ansiGray    = "\x1b[0;90m"
+	ansiClear   = "\x1b[0K"
+)
+
+// severities lists the severity levels from the most to the least severe
+var severities = []report.SeverityLevel{
+	report.SeverityLevelCritical,
+	report.SeverityLevelHigh,
+	report.SeverityLevelMedium,
+	report.SeverityLevelLow,
+	report.SeverityLevelUnknown,
+	report.SeverityLevelInfo,
+}
+
+// sectionName strips the characters GitLab does not accept in a log section name
+var sectionName = regexp.MustCompile(`[^a-z0-9_.-]`)
+
+// Console renders the gate result for job logs
+type Console struct {
+	W        io.Writer
+	Color    bool     // Color enables ANSI colors
+	Sections bool     // Sections wraps the details of each finding in a collapsed GitLab CI log section
+	Redactor Redactor // Redactor masks secrets before they are printed
+}
+
+// Print writes the summary table, the findings table and the details of every finding
+func (c *Console) Print(res *Result) {
+	findings := res.Findings()
+	sortFindings(findings)
+
+	c.printSummary(res)
+	if len(findings) == 0 {
+		return
+	}
+	fmt.Fprintln(c.W)
+	c.printFindings(findings)
+	fmt.Fprintln(c.W)
+	for i, f := range findings {
+		c.printDetails(i, f)
+	}
+}
+
+// printSummary writes the count of findings per category and severity
+func (c *Console) printSummary(res *Result) {
+	counts := make(map[report.Category]map[report.SeverityLevel]int)
+	decisions := make(map[report.Category]map[Decision]int)
+	var categories []report.Category
+	for _, rr := range res.Reports {
+		category := rr.Category()
+		if _, ok := counts[category]; !ok {
+			categories = append(categories, category)
+			counts[category] = make(map[report.SeverityLevel]int)
+			decisions[category] = make(map[Decision]int)
+		}
+		for _, f := range rr.Findings {
+			counts[category][severityOf(f.Vulnerability)]++
+			decisions[category][f.Decision]++
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i] < categories[j] })
+
+	header := []string{"CATEGORY"}
+	for _, s := range severities {
+		header = append(header, strings.ToUpper(s.String()))
+	}
+	header = append(header, "FAILED", "WARNED", "SUPPRESSED", "IGNORED")
+
+	rows := [][]cell{}
+	for _, category := range categories {
+		row := []cell{{text: string(category)}}
+		for _, s := range severities {
+			n := counts[category][s]
+			row = append(row, cell{text: fmt.Sprint(n), color: countColor(n, severityColor(s))})
+		}
+		row = append(row,
+			cell{text: fmt.Sprint(decisions[category][DecisionFail]), color: countColor(decisions[category][DecisionFail], ansiBoldRed)},
+			cell{text: fmt.Sprint(decisions[category][DecisionWarn]), color: countColor(decisions[category][DecisionWarn], ansiYellow)},
+			cell{text: fmt.Sprint(decisions[category][DecisionSuppress]), color: countColor(decisions[category][DecisionSuppress], ansiGray)},
+			cell{text: fmt.Sprint(decisions[category][DecisionIgnore]), color: countColor(decisions[category][DecisionIgnore], ansiGray)},
+		)
+		rows = append(rows, row)
+	}
+
+	fmt.Fprintln(c.W, c.paint(ansiBold, "Security gate summary"))
+	c.printTable(header, rows)
+}
+
+// printFindings writes one row per finding, grouped by category and severity
+func (c *Console) printFindings(findings []*Finding) {
+	header := []string{"DECISION", "CATEGORY", "SEVERITY", "LOCATION", "IDENTIFIER", "SCANNER", "NAME"}
+
+	rows := [][]cell{}
+	for _, f := range findings {
+		v := c.Redactor.Vulnerability(f.Vulnerability)
+		rows = append(rows, []cell{
+			{text: strings.ToUpper(f.Decision.String()), color: decisionColor(f.Decision)},
+			{text: string(v.Category)},
+			{text: severityOf(v).String(), color: severityColor(severityOf(v))},
+			{text: locationString(v.Location)},
+			{text: primaryIdentifier(v)},
+			{text: v.Scanner.Name},
+			{text: v.Name},
+		})
+	}
+
+	c.printTable(header, rows)
+}
+
+// printDetails writes the details of a finding, collapsed in a GitLab CI log section
+func (c *Console) printDetails(i int, f *Finding) {
+	v := c.Redactor.Vulnerability(f.Vulnerability)
+	title := fmt.Sprintf("%s %s %s (%s)",
+		c.paint(decisionColor(f.Decision), "["+strings.ToUpper(f.Decision.String())+"]"),
+		c.paint(severityColor(severityOf(v)), severityOf(v).String()),
+		v.Name, locationString(v.Location))
+
+	name := sectionName.ReplaceAllString(fmt.Sprintf("finding_%d_%s", i+1, strings.ToLower(string(v.Category))), "_")
+	c.sectionStart(name, title)
+
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(c.W, "  %-12s %s\n", label+":", value)
+		}
+	}
+	field("Reason", f.Reason)
+	field("ID", f.ID())
+	field("Report", f.Source)
+	field("Scanner", v.Scanner.Name)
+	field("Confidence", confidenceOf(v).String())
+	field("Message", v.Message)
+	field("Description", v.Description)
+	field("Solution", v.Solution)
+	field("Extract", v.RawSourceCodeExtract)
+	for _, id := range v.Identifiers {
+		field("Identifier", strings.TrimSpace(fmt.Sprintf("%s %s", id.Name, id.URL)))
+	}
+	for _, l := range v.Links {
+		field("Link", l.URL)
+	}
+
+	c.sectionEnd(name)
+}
+
+func (c *Console) sectionStart(name, title string) {
+	if !c.Sections {
+		fmt.Fprintln(c.W, title)
+		return
+	}
+	fmt.Fprintf(c.W, "%ssection_start:%d:%s[collapsed=true]\r%s%s\n", ansiClear, time.Now().Unix(), name, ansiClear, title)
+}
+
+func (c *Console) sectionEnd(name string) {
+	if !c.Sections {
+		return
+	}
+	fmt.Fprintf(c.W, "%ssection_end:%d:%s\r%s\n", ansiClear, time.Now().Unix(), name, ansiClear)
+}
+
+// cell is a table cell, optionally colored
+type cell struct {
+	text  string
+	color string
+}
+
+// printTable writes left aligned columns. Padding is computed on the plain text
+// so colors do not break the alignment.
+func (c *Console) printTable(header []string, rows [][]cell) {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+	for _, row := range rows {
+		for i, cl := range row {
+			if n := utf8.RuneCountInString(cl.text); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, h := range header {
+		b.WriteString(c.paint(ansiBold, pad(h, widths[i], i == len(header)-1)))
+	}
+	fmt.Fprintln(c.W, strings.TrimRight(b.String(), " "))
+
+	for _, row := range rows {
+		b.Reset()
+		for i, cl := range row {
+			b.WriteString(c.paint(cl.color, pad(cl.text, widths[i], i == len(row)-1)))
+		}
+		fmt.Fprintln(c.W, strings.TrimRight(b.String(), " "))
+	}
+}
+
+func pad(s string, width int, last bool) string {
+	if last {
+		return s
+	}
+	return s + strings.Repeat(" ", width-utf8.RuneCountInString(s)+2)
+}
+
+func (c *Console) paint(color, s string) string {
+	if !c.Color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+func severityColor(s report.SeverityLevel) string {
+	switch s {
+	case report.SeverityLevelCritical:
+		return ansiBoldRed
+	case report.SeverityLevelHigh:
+		return ansiRed
+	case report.SeverityLevelMedium:
+		return ansiYellow
+	case report.SeverityLevelLow:
+		return ansiBlue
+	}
+	return ansiGray
+}
+
+func decisionColor(d Decision) string {
+	switch d {
+	case DecisionFail:
+		return ansiBoldRed
+	case DecisionWarn:
+		return ansiYellow
+	}
+	return ansiGray
+}
+
+func countColor(n int, color string) string {
+	if n == 0 {
+		return ansiGreen
+	}
+	return color
+}
+
+// sortFindings orders findings by category, decreasing severity and location
+func sortFindings(findings []*Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		fi, fj := findings[i], findings[j]
+		if fi.Category != fj.Category {
+			return fi.Category < fj.Category
+		}
+		if si, sj := severityOf(fi.Vulnerability), severityOf(fj.Vulnerability); si != sj {
+			return si > sj
+		}
+		if fi.Location.File != fj.Location.File {
+			return fi.Location.File < fj.Location.File
+		}
+		return fi.Location.LineStart < fj.Location.LineStart
+	})
+}
+
+// locationString renders a location as file:line, falling back to the image or package
+func locationString(l report.Location) string {
+	switch {
+	case l.File != "" && l.LineStart > 0 && l.LineEnd > l.LineStart:
+		return fmt.Sprintf("%s:%d-%d", l.File, l.LineStart, l.LineEnd)
+	case l.File != "" && l.LineStart > 0:
+		return fmt.Sprintf("%s:%d", l.File, l.LineStart)
+	case l.File != "":
+		return l.File
+	case l.Image != "" && l.Dependency != nil:
+		return fmt.Sprintf("%s (%s %s)", l.Image, l.Dependency.Package.Name, l.Dependency.Version)
+	case l.Image != "":
+		return l.Image
+	case l.Dependency != nil:
+		return fmt.Sprintf("%s %s", l.Dependency.Package.Name, l.Dependency.Version)
+	}
+	return "-"
+}
+
+// primaryIdentifier returns the name of the first identifier that is not a CWE
+func primaryIdentifier(v report.Vulnerability) string {
+	for _, id := range v.Identifiers {
+		if id.Type != report.IdentifierTypeCWE {
+			return identifierLabel(id)
+		}
+	}
+	if len(v.Identifiers) > 0 {
+		return identifierLabel(v.Identifiers[0])
+	}
+	return "-"
+}
+
+func identifierLabel(id report.Identifier) string {
+	if id.Value != "" {
+		return id.Value
+	}
+	return id.Name
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// EnvVarDiffBaseSHA is the merge request diff base provided by GitLab CI
+const EnvVarDiffBaseSHA = "CI_MERGE_REQUEST_DIFF_BASE_SHA"
+
+// hunkHeader matches the new file range of a unified diff hunk, e.g. "@@ -10,2 +12,3 @@"
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// lineRange is an inclusive range of lines
+type lineRange struct {
+	Start, End int
+}
+
+// Diff holds the lines added or modified per file between two commits
+type Diff map[string][]lineRange
+
+// gitDiff computes the changed lines between base and HEAD from the local checkout.
+// core.quotePath is disabled so that only paths with control characters, quotes or backslashes are quoted.
+func gitDiff(base string) (Diff, error) {
+	cmd := exec.Command("git", "-c", "core.quotePath=false", "diff", "--no-color", "--no-ext-diff", "--unified=0", base, "HEAD") // #nosec
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s HEAD: %w: %s", base, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseDiff(out)
+}
+
+// parseDiff extracts the changed line ranges of the new files from a unified diff
+func parseDiff(b []byte) (Diff, error) {
+	d := make(Diff)
+
+	var file string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			var err error
+			if file, err = diffPath(strings.TrimPrefix(line, "+++ ")); err != nil {
+				return nil, err
+			}
+			if file == "" {
+				continue
+			}
+			if _, ok := d[file]; !ok {
+				d[file] = nil
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid hunk header %q", line)
+			}
+			start, _ := strconv.Atoi(m[1])
+			count := 1
+			if m[2] != "" {
+				count, _ = strconv.Atoi(m[2])
+			}
+			if count > 0 {
+				d[file] = append(d[file], lineRange{start, start + count - 1})
+			}
+		}
+	}
+
+	return d, scanner.Err()
+}
+
+// diffPath returns the new file path of a "+++" line, empty for a deleted file. Git quotes the paths
+// holding special characters the way C strings are and follows the paths holding spaces with a tab.
+func diffPath(s string) (string, error) {
+	s = strings.TrimSuffix(s, "\t")
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted path %s: %w", s, err)
+		}
+		s = unquoted
+	}
+	if s == "/dev/null" {
+		return "", nil
+	}
+	return strings.TrimPrefix(s, "b/"), nil
+}
+
+// Changed is true when the location overlaps lines added or modified in the diff.
+// Locations without line information are changed when their file is part of the diff.
+func (d Diff) Changed(l report.Location) bool {
+	ranges, ok := d[strings.TrimPrefix(l.File, "./")]
+	if !ok {
+		return false
+	}
+	if l.LineStart == 0 {
+		return true
+	}
+
+	end := l.LineEnd
+	if end < l.LineStart {
+		end = l.LineStart
+	}
+	for _, r := range ranges {
+		if l.LineStart <= r.End && end >= r.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// diffAware is true for the categories whose findings point at source lines
+func diffAware(category report.Category) bool {
+	return category == report.CategorySast || category == report.CategorySecretDetection
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want Diff
+	}{
+		{
+			name: "modified file",
+			diff: `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -10,0 +11,2 @@ func main() {
++	a()
++	b()
+@@ -20 +22 @@ func main() {
+-	c()
++	d()
+`,
+			want: Diff{"main.go": {{11, 12}, {22, 22}}},
+		},
+		{
+			name: "deleted lines only",
+			diff: `--- a/main.go
++++ b/main.go
+@@ -5,2 +4,0 @@
+-	a()
+-	b()
+`,
+			want: Diff{"main.go": nil},
+		},
+		{
+			name: "deleted file",
+			diff: `--- a/old.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package old
+`,
+			want: Diff{},
+		},
+		{
+			name: "path with spaces",
+			diff: "--- /dev/null\n+++ b/a b.go\t\n@@ -0,0 +1 @@\n+x\n",
+			want: Diff{"a b.go": {{1, 1}}},
+		},
+		{
+			name: "quoted path",
+			diff: `--- /dev/null
++++ "b/q\"t.go"
+@@ -0,0 +1 @@
++x
+`,
+			want: Diff{`q"t.go`: {{1, 1}}},
+		},
+		{
+			name: "quoted octal path",
+			diff: `--- /dev/null
++++ "b/\303\244.go"
+@@ -0,0 +1,2 @@
++x
++y
+`,
+			want: Diff{"ä.go": {{1, 2}}},
+		},
+		{
+			name: "unquoted non-ASCII path",
+			diff: "--- /dev/null\n+++ b/ä.go\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+			want: Diff{"ä.go": {{1, 2}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDiff([]byte(tt.diff))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDiffErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+	}{
+		{"invalid hunk header", "+++ b/main.go\n@@ -1 +x @@\n"},
+		{"invalid quoted path", "+++ \"b/main.go\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseDiff([]byte(tt.diff)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// reportPattern matches the artifact names produced by the GitLab security analyzers
+// (gl-sast-report.json, gl-secret-detection-report.json, ...)
+const reportPattern = "gl-*-report.json"
+
+// discoveryPatterns are the file names of the known formats picked up when searching directories
+var discoveryPatterns = []string{reportPattern, "*.sarif", "gitleaks*.json", "trufflehog*.json", "trivy*.json", "grype*.json"}
+
+// discoverReports expands the given inputs into a sorted list of report files.
+// An input can be a file, a directory (searched recursively for the given file name patterns) or a glob pattern.
+// The files and directories matching a skip path or glob are left out of the directory searches.
+func discoverReports(inputs []string, patterns []string, skip []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	var skipped []string
+	for _, s := range skip {
+		abs, err := filepath.Abs(s)
+		if err != nil {
+			return nil, err
+		}
+		skipped = append(skipped, abs)
+	}
+
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, input := range inputs {
+		matches := []string{input}
+		if strings.ContainsAny(input, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(input); err != nil {
+				return nil, configError("", fmt.Errorf("invalid report pattern %q: %w", input, err))
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, readError(match, err)
+			}
+
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			err = filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if path != match && isSkipped(path, skipped) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if d.IsDir() {
+					if d.Name() == ".git" {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				for _, pattern := range patterns {
+					if ok, _ := filepath.Match(pattern, d.Name()); ok {
+						add(path)
+						break
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, readError(match, err)
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// isSkipped is true when the path matches one of the absolute skip paths or globs
+func isSkipped(path string, skipped []string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range skipped {
+		if ok, _ := filepath.Match(pattern, abs); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryFromFilename derives the report category from a GitLab artifact name,
+// e.g. gl-secret-detection-report.json yields secret_detection.
+func categoryFromFilename(path string) string {
+	name := filepath.Base(path)
+	if ok, _ := filepath.Match(reportPattern, name); !ok {
+		return ""
+	}
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "gl-"), "-report.json")
+	return strings.ReplaceAll(name, "-", "_")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// exclusionPresets are the named sets of patterns enabled with -exclude-preset
+var exclusionPresets = map[string][]string{
+	"vendor":       {"**/vendor/**"},
+	"node_modules": {"**/node_modules/**"},
+	"testdata":     {"**/testdata/**"},
+	"go-tests":     {"**/*_test.go"},
+}
+
+// Exclusions are the doublestar patterns of the paths whose findings and dependency files are dropped.
+// A pattern starting with ! includes again the paths excluded by the patterns before it, the last match wins.
+type Exclusions struct {
+	Presets  []string // Presets are the names of the enabled exclusionPresets, applied before Patterns
+	Patterns []string // Patterns are the user patterns
+}
+
+// Excluded lists what the exclusions removed from a report
+type Excluded struct {
+	CompareKeys     map[string][]string // CompareKeys are the compare keys of the removed vulnerabilities per matching pattern
+	DependencyFiles []string            // DependencyFiles are the paths of the removed dependency files
+}
+
+// Count returns the number of vulnerabilities removed
+func (e Excluded) Count() int {
+	n := 0
+	for _, keys := range e.CompareKeys {
+		n += len(keys)
+	}
+	return n
+}
+
+// patterns returns the preset patterns followed by the user patterns
+func (e *Exclusions) patterns() []string {
+	var patterns []string
+	for _, name := range e.Presets {
+		patterns = append(patterns, exclusionPresets[name]...)
+	}
+	return append(patterns, e.Patterns...)
+}
+
+// Empty is true when no pattern is configured
+func (e *Exclusions) Empty() bool {
+	return len(e.Presets) == 0 && len(e.Patterns) == 0
+}
+
+// Match returns the last pattern matching path and whether the path is excluded
+func (e *Exclusions) Match(path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+
+	var matched string
+	var excluded bool
+	for _, pattern := range e.patterns() {
+		negated := strings.HasPrefix(pattern, "!")
+		if ok, _ := doublestar.Match(strings.TrimPrefix(pattern, "!"), path); ok {
+			matched, excluded = pattern, !negated
+		}
+	}
+	return matched, excluded
+}
+
+// Exclude removes the vulnerabilities, remediations and dependency files of r located in excluded paths
+func (e *Exclusions) Exclude(r *report.Report) Excluded {
+	excluded := Excluded{CompareKeys: make(map[string][]string)}
+	if e.Empty() {
+		return excluded
+	}
+
+	for _, v := range r.Vulnerabilities {
+		if pattern, ok := e.Match(v.Location.File); ok {
+			excluded.CompareKeys[pattern] = append(excluded.CompareKeys[pattern], v.CompareKey)
+		}
+	}
+	for _, df := range r.DependencyFiles {
+		if _, ok := e.Match(df.Path); ok {
+			excluded.DependencyFiles = append(excluded.DependencyFiles, df.Path)
+		}
+	}
+
+	r.ExcludePaths(func(path string) bool {
+		_, ok := e.Match(path)
+		return ok
+	})
+	return excluded
+}
+
+// Summary describes the removed vulnerabilities per pattern, listing the first compare keys
+func (e Excluded) Summary(max int) []string {
+	var patterns []string
+	for pattern := range e.CompareKeys {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	var lines []string
+	for _, pattern := range patterns {
+		keys := e.CompareKeys[pattern]
+		shown := keys
+		if len(shown) > max {
+			shown = shown[:max]
+		}
+		line := fmt.Sprintf("%s: %d vulnerabilities (%s", pattern, len(keys), strings.Join(shown, ", "))
+		if len(keys) > len(shown) {
+			line += fmt.Sprintf(", and %d more", len(keys)-len(shown))
+		}
+		lines = append(lines, line+")")
+	}
+	return lines
+}
+
+// excludeFlag is a flag.Value adding an exclusion pattern, it can be repeated
+type excludeFlag struct {
+	exclusions *Exclusions
+}
+
+func (f excludeFlag) String() string {
+	if f.exclusions == nil {
+		return ""
+	}
+	return strings.Join(f.exclusions.Patterns, " ")
+}
+
+func (f excludeFlag) Set(s string) error {
+	if !doublestar.ValidatePattern(strings.TrimPrefix(s, "!")) {
+		return fmt.Errorf("invalid exclusion pattern %q", s)
+	}
+	f.exclusions.Patterns = append(f.exclusions.Patterns, s)
+	return nil
+}
+
+// excludePresetFlag is a flag.Value enabling comma separated exclusion presets
+type excludePresetFlag struct {
+	exclusions *Exclusions
+}
+
+func (f excludePresetFlag) String() string {
+	if f.exclusions == nil {
+		return ""
+	}
+	return strings.Join(f.exclusions.Presets, ",")
+}
+
+func (f excludePresetFlag) Set(s string) error {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := exclusionPresets[name]; !ok {
+			return fmt.Errorf("unknown exclusion preset %q, expected one of %s", name, strings.Join(exclusionPresetNames(), ", "))
+		}
+		f.exclusions.Presets = append(f.exclusions.Presets, name)
+	}
+	return nil
+}
+
+// exclusionPresetNames returns the sorted names of the exclusion presets
+func exclusionPresetNames() []string {
+	var names []string
+	for name := range exclusionPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ExitCode is the exit status of the gate. The codes are stable so that pipelines
+// can tell the outcomes apart, e.g. with allow_failure:exit_codes.
+type ExitCode int
+
+const (
+	ExitPass          ExitCode = 0 // ExitPass is returned when no finding fails or warns
+	ExitFindings      ExitCode = 1 // ExitFindings is returned when findings fail the gate
+	ExitConfig        ExitCode = 2 // ExitConfig is returned for invalid flags, policy, suppression, mapping or ruleset files
+	ExitWarnings      ExitCode = 3 // ExitWarnings is returned when findings only warn and -warnings-exit-code asks for it
+	ExitReportMissing ExitCode = 4 // ExitReportMissing is returned when a report is not found or cannot be read
+	ExitParse         ExitCode = 5 // ExitParse is returned when a report cannot be parsed
+	ExitError         ExitCode = 6 // ExitError is returned for any other error, e.g. writing an output
+)
+
+var exitStatuses = map[ExitCode]string{
+	ExitPass:          "pass",
+	ExitFindings:      "findings",
+	ExitConfig:        "config_invalid",
+	ExitWarnings:      "warnings",
+	ExitReportMissing: "report_missing",
+	ExitParse:         "parse_failure",
+	ExitError:         "error",
+}
+
+func (c ExitCode) String() string {
+	if s, ok := exitStatuses[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("exit_%d", int(c))
+}
+
+// exitCodesUsage documents the exit codes in the command usage
+const exitCodesUsage = `
+Exit codes:
+  0  no finding fails or warns
+  1  findings fail the gate
+  2  configuration invalid (flags, policy, suppression, mapping or ruleset files)
+  3  findings only warn, with -warnings-exit-code 3 (warnings pass by default)
+  4  report missing or unreadable
+  5  report parse failure
+  6  other error, e.g. writing an output
+`
+
+// GateError is an error carrying the exit code it ends the gate with
+type GateError struct {
+	Code ExitCode
+	File string
+	Err  error
+}
+
+func (e *GateError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GateError) Unwrap() error {
+	return e.Err
+}
+
+// configError marks err as a configuration error
+func configError(file string, err error) error {
+	return &GateError{Code: ExitConfig, File: file, Err: err}
+}
+
+// readError marks err as a missing report when the file cannot be read
+func readError(file string, err error) error {
+	return &GateError{Code: ExitReportMissing, File: file, Err: err}
+}
+
+// parseError marks err as a report parse failure
+func parseError(file string, err error) error {
+	return &GateError{Code: ExitParse, File: file, Err: err}
+}
+
+// exitCodeOf returns the exit code of err, ExitError when it is not a GateError
+func exitCodeOf(err error) (ExitCode, string) {
+	var ge *GateError
+	if errors.As(err, &ge) {
+		return ge.Code, ge.File
+	}
+	return ExitError, ""
+}
+
+// Summary is the machine-readable outcome of the gate, written as JSON with -summary
+type Summary struct {
+	Status     string         `json:"status"`
+	ExitCode   ExitCode       `json:"exit_code"`
+	Reports    int            `json:"reports"`
+	Failed     int            `json:"failed"`
+	Warned     int            `json:"warned"`
+	Suppressed int            `json:"suppressed"`
+	Ignored    int            `json:"ignored"`
+	Errors     []SummaryError `json:"errors"`
+
+	// Findings are the failing and warning findings
+	Findings []SummaryFinding `json:"findings"`
+
+	path string
+}
+
+// SummaryFinding is the decision of the gate on a finding
+type SummaryFinding struct {
+	ID         string `json:"id"`
+	CompareKey string `json:"compare_key"`
+	Name       string `json:"name"`
+	Category   string `json:"category"`
+	Decision   string `json:"decision"`
+	Reason     string `json:"reason"`
+}
+
+// SummaryError is an error that stopped the gate
+type SummaryError struct {
+	Status  string `json:"status"`
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error logs err and records it in the summary, the first error sets the exit code
+func (s *Summary) Error(err error) {
+	log.Error(err)
+	code, file := exitCodeOf(err)
+	if len(s.Errors) == 0 {
+		s.ExitCode = code
+	}
+	s.Errors = append(s.Errors, SummaryError{Status: code.String(), File: file, Message: err.Error()})
+}
+
+// Fatal records err and exits
+func (s *Summary) Fatal(err error) {
+	s.Error(err)
+	s.Exit()
+}
+
+// Result records the decisions of the gate and sets the exit code when no error occurred
+func (s *Summary) Result(res *Result, warningsExitCode ExitCode, redactor Redactor) {
+	s.Reports = len(res.Reports)
+	s.Failed = len(res.Filter(DecisionFail))
+	s.Warned = len(res.Filter(DecisionWarn))
+	s.Suppressed = len(res.Filter(DecisionSuppress))
+	s.Ignored = len(res.Filter(DecisionIgnore))
+	for _, f := range res.Findings() {
+		if f.Decision == DecisionFail || f.Decision == DecisionWarn {
+			v := redactor.Vulnerability(f.Vulnerability)
+			s.Findings = append(s.Findings, SummaryFinding{
+				ID:         f.ID(),
+				CompareKey: v.CompareKey,
+				Name:       v.Name,
+				Category:   string(f.Category),
+				Decision:   f.Decision.String(),
+				Reason:     f.Reason,
+			})
+		}
+	}
+	if len(s.Errors) > 0 {
+		return
+	}
+	switch {
+	case s.Failed > 0:
+		s.ExitCode = ExitFindings
+	case s.Warned > 0:
+		s.ExitCode = warningsExitCode
+	default:
+		s.ExitCode = ExitPass
+	}
+}
+
+// Exit writes the summary file when requested and exits with the summary exit code
+func (s *Summary) Exit() {
+	s.Status = s.ExitCode.String()
+	if s.Errors == nil {
+		s.Errors = []SummaryError{}
+	}
+	if s.Findings == nil {
+		s.Findings = []SummaryFinding{}
+	}
+	if s.path != "" {
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err == nil {
+			err = os.WriteFile(s.path, append(b, '\n'), 0644)
+		}
+		if err != nil {
+			log.Errorf("Writing summary %s: %s", s.path, err)
+			if s.ExitCode == ExitPass {
+				s.ExitCode = ExitError
+			}
+		}
+	}
+	os.Exit(int(s.ExitCode))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCodeOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode ExitCode
+		wantFile string
+	}{
+		{"config", configError("gate.yml", errors.New("unknown key")), ExitConfig, "gate.yml"},
+		{"read", readError("gl-sast-report.json", os.ErrNotExist), ExitReportMissing, "gl-sast-report.json"},
+		{"parse", parseError("gl-sast-report.json", errors.New("unexpected EOF")), ExitParse, "gl-sast-report.json"},
+		{"wrapped", fmt.Errorf("loading: %w", parseError("scan.sarif", errors.New("invalid"))), ExitParse, "scan.sarif"},
+		{"without file", configError("", errors.New("invalid flag")), ExitConfig, ""},
+		{"other", errors.New("disk full"), ExitError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, file := exitCodeOf(tt.err)
+			if code != tt.wantCode || file != tt.wantFile {
+				t.Errorf("got %d %q, want %d %q", code, file, tt.wantCode, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestSummaryError(t *testing.T) {
+	var s Summary
+	s.Error(readError("a.json", os.ErrNotExist))
+	s.Error(configError("gate.yml", errors.New("unknown key")))
+
+	if s.ExitCode != ExitReportMissing {
+		t.Errorf("exit code %s, want the first error %s", s.ExitCode, ExitReportMissing)
+	}
+	if len(s.Errors) != 2 || s.Errors[1].Status != "config_invalid" || s.Errors[1].File != "gate.yml" {
+		t.Errorf("got errors %+v", s.Errors)
+	}
+}
+
+func TestGateExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "clean", "gl-secret-detection-report.json"), secretReport())
+	writeFile(t, filepath.Join(dir, "leak", "gl-secret-detection-report.json"), secretReport("a.env"))
+	writeFile(t, filepath.Join(dir, "broken", "gl-secret-detection-report.json"), `{"vulnerabilities":[`)
+	writeFile(t, filepath.Join(dir, "warn.yml"), "rules:\n  - name: warn on secrets\n    when: category == \"secret_detection\"\n    decision: warn\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want ExitCode
+	}{
+		{"pass", []string{"clean"}, ExitPass},
+		{"findings", []string{"leak"}, ExitFindings},
+		{"warnings pass by default", []string{"-rules", "warn.yml", "leak"}, ExitPass},
+		{"warnings exit code", []string{"-rules", "warn.yml", "-warnings-exit-code", "3", "leak"}, ExitWarnings},
+		{"invalid flag", []string{"-severity", "severe", "leak"}, ExitConfig},
+		{"missing rule file", []string{"-rules", "missing.yml", "leak"}, ExitConfig},
+		{"missing report", []string{"missing/gl-sast-report.json"}, ExitReportMissing},
+		{"parse failure", []string{"broken"}, ExitParse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], append([]string{"-no-color"}, tt.args...)...) // #nosec G204
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), envRunGate+"=1", "GITLAB_CI=false")
+			output, err := cmd.CombinedOutput()
+
+			var code ExitCode
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = ExitCode(exitErr.ExitCode())
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.want {
+				t.Errorf("exit code %d (%s), want %d (%s):\n%s", code, code, tt.want, tt.want, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,730 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// exprType is the type of an expression, checked when a rule is loaded
+type exprType int
+
+const (
+	typeBool exprType = iota
+	typeString
+	typeNumber
+	typeSeverity
+	typeConfidence
+	typeList
+	typeLevel // typeLevel is a bare level name, typed by the severity or confidence it is compared to
+)
+
+func (t exprType) String() string {
+	switch t {
+	case typeBool:
+		return "bool"
+	case typeString:
+		return "string"
+	case typeNumber:
+		return "number"
+	case typeSeverity:
+		return "severity"
+	case typeConfidence:
+		return "confidence"
+	case typeList:
+		return "list"
+	}
+	return "level"
+}
+
+// exprField is a finding field available to expressions
+type exprField struct {
+	typ   exprType
+	value func(f *Finding) interface{}
+}
+
+// exprFields are the fields of a finding available to expressions,
+// taken from report.Vulnerability and its Location
+var exprFields = map[string]exprField{
+	"category":    {typeString, func(f *Finding) interface{} { return string(f.Category) }},
+	"name":        {typeString, func(f *Finding) interface{} { return f.Name }},
+	"message":     {typeString, func(f *Finding) interface{} { return f.Message }},
+	"description": {typeString, func(f *Finding) interface{} { return f.Description }},
+	"solution":    {typeString, func(f *Finding) interface{} { return f.Solution }},
+	"compare_key": {typeString, func(f *Finding) interface{} { return f.CompareKey }},
+	"severity":    {typeSeverity, func(f *Finding) interface{} { return severityOf(f.Vulnerability) }},
+	"confidence":  {typeConfidence, func(f *Finding) interface{} { return confidenceOf(f.Vulnerability) }},
+	"source":      {typeString, func(f *Finding) interface{} { return f.Source }},
+	"identifiers": {typeList, func(f *Finding) interface{} {
+		var values []string
+		for _, id := range f.Identifiers {
+			values = append(values, id.Value)
+		}
+		return values
+	}},
+	"identifier_types": {typeList, func(f *Finding) interface{} {
+		var types []string
+		for _, id := range f.Identifiers {
+			types = append(types, string(id.Type))
+		}
+		return types
+	}},
+	"scanner.id":                {typeString, func(f *Finding) interface{} { return f.Scanner.ID }},
+	"scanner.name":              {typeString, func(f *Finding) interface{} { return f.Scanner.Name }},
+	"file":                      {typeString, func(f *Finding) interface{} { return f.Location.File }},
+	"line":                      {typeNumber, func(f *Finding) interface{} { return float64(f.Location.LineStart) }},
+	"location.file":             {typeString, func(f *Finding) interface{} { return f.Location.File }},
+	"location.start_line":       {typeNumber, func(f *Finding) interface{} { return float64(f.Location.LineStart) }},
+	"location.end_line":         {typeNumber, func(f *Finding) interface{} { return float64(f.Location.LineEnd) }},
+	"location.class":            {typeString, func(f *Finding) interface{} { return f.Location.Class }},
+	"location.method":           {typeString, func(f *Finding) interface{} { return f.Location.Method }},
+	"location.image":            {typeString, func(f *Finding) interface{} { return f.Location.Image }},
+	"location.operating_system": {typeString, func(f *Finding) interface{} { return f.Location.OperatingSystem }},
+	"dependency.package.name": {typeString, func(f *Finding) interface{} {
+		if f.Location.Dependency == nil {
+			return ""
+		}
+		return f.Location.Dependency.Package.Name
+	}},
+	"dependency.version": {typeString, func(f *Finding) interface{} {
+		if f.Location.Dependency == nil {
+			return ""
+		}
+		return f.Location.Dependency.Version
+	}},
+	"dependency.direct": {typeBool, func(f *Finding) interface{} {
+		return f.Location.Dependency != nil && f.Location.Dependency.Direct
+	}},
+}
+
+// exprMethods are the methods callable on strings and lists, all taking a string and returning a bool
+var exprMethods = map[exprType]map[string]func(recv interface{}, arg string) bool{
+	typeString: {
+		"matches": func(recv interface{}, arg string) bool {
+			ok, _ := doublestar.Match(arg, recv.(string))
+			return ok
+		},
+		"contains":   func(recv interface{}, arg string) bool { return strings.Contains(recv.(string), arg) },
+		"startsWith": func(recv interface{}, arg string) bool { return strings.HasPrefix(recv.(string), arg) },
+		"endsWith":   func(recv interface{}, arg string) bool { return strings.HasSuffix(recv.(string), arg) },
+	},
+	typeList: {
+		"contains": func(recv interface{}, arg string) bool { return containsString(recv.([]string), arg) },
+	},
+}
+
+// Expr is a compiled boolean expression over a finding, e.g.
+// category == "dependency_scanning" && severity >= High && dependency.direct && !file.matches("test/**")
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// compileExpr parses and type-checks an expression, rejecting unknown fields and methods
+func compileExpr(src string) (*Expr, error) {
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("column %d: unexpected %q", t.pos+1, t.text)
+	}
+
+	typ, err := checkExpr(root)
+	if err != nil {
+		return nil, err
+	}
+	if typ != typeBool {
+		return nil, fmt.Errorf("expression is a %s, expected a bool", typ)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Match evaluates the expression against a finding
+func (e *Expr) Match(f *Finding) bool {
+	return e.root.eval(f).(bool)
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// exprOperators are the operators and punctuation of expressions, longest first
+var exprOperators = []string{"&&", "||", "==", "!=", ">=", "<=", ">", "<", "!", "(", ")", "[", "]", ",", "."}
+
+func lexExpr(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && rune(src[end]) != c {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("column %d: unterminated string", i+1)
+			}
+			text := src[i+1 : end]
+			if c == '"' {
+				s, err := strconv.Unquote(src[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("column %d: invalid string: %w", i+1, err)
+				}
+				text = s
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: i})
+			i = end + 1
+		case unicode.IsDigit(c):
+			end := i
+			for end < len(src) && (unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[i:end], pos: i})
+			i = end
+		case unicode.IsLetter(c) || c == '_':
+			end := i
+			for end < len(src) && (unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end])) || src[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+			for _, o := range exprOperators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("column %d: unexpected character %q", i+1, c)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+type exprParser struct {
+	tokens []token
+	i      int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOp && t.text == op
+}
+
+func (p *exprParser) expect(op string) error {
+	if t := p.next(); t.kind != tokenOp || t.text != op {
+		return fmt.Errorf("column %d: expected %q", t.pos+1, op)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		t := p.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: t.pos, op: t.text, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		t := p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: t.pos, op: t.text, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("!") {
+		t := p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{pos: t.pos, x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tokenOp && (t.text == "==" || t.text == "!=" || t.text == ">=" || t.text == "<=" || t.text == ">" || t.text == "<"):
+	case t.kind == tokenIdent && t.text == "in":
+	default:
+		return x, nil
+	}
+	p.next()
+	y, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{pos: t.pos, op: t.text, x: x, y: y}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &literalNode{pos: t.pos, typ: typeString, value: t.text}, nil
+	case tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: invalid number %q", t.pos+1, t.text)
+		}
+		return &literalNode{pos: t.pos, typ: typeNumber, value: n}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			return &literalNode{pos: t.pos, typ: typeBool, value: t.text == "true"}, nil
+		}
+		names := []string{t.text}
+		for p.isOp(".") {
+			p.next()
+			name := p.next()
+			if name.kind != tokenIdent {
+				return nil, fmt.Errorf("column %d: expected a name after \".\"", name.pos+1)
+			}
+			if p.isOp("(") {
+				recv := &identNode{pos: t.pos, name: strings.Join(names, ".")}
+				return p.parseCall(recv, name)
+			}
+			names = append(names, name.text)
+		}
+		return &identNode{pos: t.pos, name: strings.Join(names, ".")}, nil
+	case tokenOp:
+		switch t.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			list := &listNode{pos: t.pos}
+			for !p.isOp("]") {
+				if len(list.items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				item, err := p.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+			}
+			p.next()
+			return list, nil
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("column %d: unexpected end of expression", t.pos+1)
+	}
+	return nil, fmt.Errorf("column %d: unexpected %q", t.pos+1, t.text)
+}
+
+func (p *exprParser) parseCall(recv exprNode, method token) (exprNode, error) {
+	p.next() // (
+	call := &callNode{pos: method.pos, recv: recv, method: method.text}
+	for !p.isOp(")") {
+		if len(call.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.next()
+	return call, nil
+}
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	position() int
+	eval(f *Finding) interface{}
+	String() string
+}
+
+type literalNode struct {
+	pos   int
+	typ   exprType
+	value interface{}
+}
+
+type identNode struct {
+	pos   int
+	name  string
+	field *exprField
+	level *literalNode // level is set once a level name is typed by a comparison
+}
+
+type notNode struct {
+	pos int
+	x   exprNode
+}
+
+type binaryNode struct {
+	pos  int
+	op   string
+	x, y exprNode
+}
+
+type callNode struct {
+	pos    int
+	recv   exprNode
+	method string
+	args   []exprNode
+	fn     func(recv interface{}, arg string) bool
+}
+
+type listNode struct {
+	pos   int
+	items []exprNode
+}
+
+func (n *literalNode) position() int { return n.pos }
+func (n *identNode) position() int   { return n.pos }
+func (n *notNode) position() int     { return n.pos }
+func (n *binaryNode) position() int  { return n.pos }
+func (n *callNode) position() int    { return n.pos }
+func (n *listNode) position() int    { return n.pos }
+
+func (n *literalNode) String() string {
+	switch v := n.value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(n.value)
+}
+
+func (n *identNode) String() string {
+	if n.level != nil {
+		return n.level.String()
+	}
+	return n.name
+}
+
+func (n *notNode) String() string {
+	return "!" + n.x.String()
+}
+
+func (n *binaryNode) String() string {
+	s := n.x.String() + " " + n.op + " " + n.y.String()
+	if n.op == "&&" || n.op == "||" {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (n *callNode) String() string {
+	var args []string
+	for _, arg := range n.args {
+		args = append(args, arg.String())
+	}
+	return n.recv.String() + "." + n.method + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (n *listNode) String() string {
+	var items []string
+	for _, item := range n.items {
+		items = append(items, item.String())
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func (n *literalNode) eval(*Finding) interface{} {
+	return n.value
+}
+
+func (n *identNode) eval(f *Finding) interface{} {
+	if n.level != nil {
+		return n.level.value
+	}
+	return n.field.value(f)
+}
+
+func (n *notNode) eval(f *Finding) interface{} {
+	return !n.x.eval(f).(bool)
+}
+
+func (n *binaryNode) eval(f *Finding) interface{} {
+	switch n.op {
+	case "&&":
+		return n.x.eval(f).(bool) && n.y.eval(f).(bool)
+	case "||":
+		return n.x.eval(f).(bool) || n.y.eval(f).(bool)
+	case "in":
+		x := n.x.eval(f).(string)
+		return containsString(n.y.eval(f).([]string), x)
+	}
+
+	x, y := n.x.eval(f), n.y.eval(f)
+	switch n.op {
+	case "==":
+		return x == y
+	case "!=":
+		return x != y
+	}
+
+	cmp := compareValues(x, y)
+	switch n.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (n *callNode) eval(f *Finding) interface{} {
+	return n.fn(n.recv.eval(f), n.args[0].eval(f).(string))
+}
+
+func (n *listNode) eval(f *Finding) interface{} {
+	values := make([]string, 0, len(n.items))
+	for _, item := range n.items {
+		values = append(values, item.eval(f).(string))
+	}
+	return values
+}
+
+// compareValues orders two values of the same ordered type
+func compareValues(x, y interface{}) int {
+	var a, b float64
+	switch v := x.(type) {
+	case float64:
+		a, b = v, y.(float64)
+	case report.SeverityLevel:
+		a, b = float64(v), float64(y.(report.SeverityLevel))
+	case report.ConfidenceLevel:
+		a, b = float64(v), float64(y.(report.ConfidenceLevel))
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// checkExpr returns the type of a node, resolving fields and level names
+func checkExpr(n exprNode) (exprType, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.typ, nil
+
+	case *identNode:
+		if field, ok := exprFields[n.name]; ok {
+			n.field = &field
+			return field.typ, nil
+		}
+		if isLevelName(n.name) {
+			return typeLevel, nil
+		}
+		return 0, fmt.Errorf("column %d: unknown field %q", n.pos+1, n.name)
+
+	case *notNode:
+		typ, err := checkExpr(n.x)
+		if err != nil {
+			return 0, err
+		}
+		if typ != typeBool {
+			return 0, fmt.Errorf("column %d: ! expects a bool, got a %s", n.pos+1, typ)
+		}
+		return typeBool, nil
+
+	case *listNode:
+		for _, item := range n.items {
+			typ, err := checkExpr(item)
+			if err != nil {
+				return 0, err
+			}
+			if typ != typeString {
+				return 0, fmt.Errorf("column %d: lists hold strings, got a %s", item.position()+1, typ)
+			}
+		}
+		return typeList, nil
+
+	case *callNode:
+		recv, err := checkExpr(n.recv)
+		if err != nil {
+			return 0, err
+		}
+		fn, ok := exprMethods[recv][n.method]
+		if !ok {
+			return 0, fmt.Errorf("column %d: unknown method %q of %s", n.pos+1, n.method, recv)
+		}
+		if len(n.args) != 1 {
+			return 0, fmt.Errorf("column %d: %s expects one argument", n.pos+1, n.method)
+		}
+		arg, err := checkExpr(n.args[0])
+		if err != nil {
+			return 0, err
+		}
+		if arg != typeString {
+			return 0, fmt.Errorf("column %d: %s expects a string, got a %s", n.pos+1, n.method, arg)
+		}
+		if lit, ok := n.args[0].(*literalNode); ok && n.method == "matches" && !doublestar.ValidatePattern(lit.value.(string)) {
+			return 0, fmt.Errorf("column %d: invalid pattern %q", lit.pos+1, lit.value)
+		}
+		n.fn = fn
+		return typeBool, nil
+
+	case *binaryNode:
+		x, err := checkExpr(n.x)
+		if err != nil {
+			return 0, err
+		}
+		y, err := checkExpr(n.y)
+		if err != nil {
+			return 0, err
+		}
+
+		switch n.op {
+		case "&&", "||":
+			if x != typeBool || y != typeBool {
+				return 0, fmt.Errorf("column %d: %s expects bools, got a %s and a %s", n.pos+1, n.op, x, y)
+			}
+			return typeBool, nil
+		case "in":
+			if x != typeString || y != typeList {
+				return 0, fmt.Errorf("column %d: in expects a string and a list, got a %s and a %s", n.pos+1, x, y)
+			}
+			return typeBool, nil
+		}
+
+		if x == typeLevel {
+			if x, err = typeLevelName(n.x, y); err != nil {
+				return 0, err
+			}
+		}
+		if y == typeLevel {
+			if y, err = typeLevelName(n.y, x); err != nil {
+				return 0, err
+			}
+		}
+		if x != y {
+			return 0, fmt.Errorf("column %d: cannot compare a %s to a %s", n.pos+1, x, y)
+		}
+		switch n.op {
+		case "==", "!=":
+			if x == typeList {
+				return 0, fmt.Errorf("column %d: cannot compare lists, use contains", n.pos+1)
+			}
+		default:
+			if x != typeNumber && x != typeSeverity && x != typeConfidence {
+				return 0, fmt.Errorf("column %d: %s does not apply to a %s", n.pos+1, n.op, x)
+			}
+		}
+		return typeBool, nil
+	}
+	return 0, fmt.Errorf("unexpected expression")
+}
+
+// isLevelName is true for the names of severity or confidence levels
+func isLevelName(name string) bool {
+	_, severityErr := parseSeverity(name)
+	_, confidenceErr := parseConfidence(name)
+	return severityErr == nil || confidenceErr == nil
+}
+
+// typeLevelName resolves a level name compared to a severity or a confidence
+func typeLevelName(n exprNode, other exprType) (exprType, error) {
+	ident := n.(*identNode)
+	switch other {
+	case typeSeverity:
+		level, err := parseSeverity(ident.name)
+		if err != nil {
+			return 0, fmt.Errorf("column %d: %w", ident.pos+1, err)
+		}
+		ident.level = &literalNode{pos: ident.pos, typ: typeSeverity, value: level}
+	case typeConfidence:
+		level, err := parseConfidence(ident.name)
+		if err != nil {
+			return 0, fmt.Errorf("column %d: %w", ident.pos+1, err)
+		}
+		ident.level = &literalNode{pos: ident.pos, typ: typeConfidence, value: level}
+	default:
+		return 0, fmt.Errorf("column %d: %s must be compared to severity or confidence", ident.pos+1, ident.name)
+	}
+	return other, nil
+}
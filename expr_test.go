@@ -0,0 +1,168 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+func TestCompileExpr(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`dependency.direct`, `dependency.direct`},
+		{`severity >= High`, `severity >= High`},
+		{`High <= severity`, `High <= severity`},
+		{`confidence == confirmed`, `confidence == Confirmed`},
+		{`line > 10`, `line > 10`},
+		{`!file.matches("test/**")`, `!file.matches("test/**")`},
+		{`category in ["sast", "secret_detection"]`, `category in ["sast", "secret_detection"]`},
+		{`identifier_types.contains("cwe")`, `identifier_types.contains("cwe")`},
+		{`dependency.direct || severity >= High && line > 1`, `(dependency.direct || (severity >= High && line > 1))`},
+		{`(dependency.direct || severity >= High) && line > 1`, `((dependency.direct || severity >= High) && line > 1)`},
+		{`!!dependency.direct`, `!!dependency.direct`},
+		{
+			`category == "dependency_scanning" && severity >= High && dependency.direct && !file.matches("test/**")`,
+			`(((category == "dependency_scanning" && severity >= High) && dependency.direct) && !file.matches("test/**"))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := compileExpr(tt.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := e.root.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileExprErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		// syntax
+		{``, `column 1: unexpected end of expression`},
+		{`severity >=`, `column 12: unexpected end of expression`},
+		{`(dependency.direct`, `column 19: expected ")"`},
+		{`dependency.direct dependency.direct`, `column 19: unexpected "dependency"`},
+		{`name == "x`, `column 9: unterminated string`},
+		{`name # "x"`, `column 6: unexpected character '#'`},
+
+		// types
+		{`severtiy >= High`, `column 1: unknown field "severtiy"`},
+		{`file.glob("*.go")`, `column 6: unknown method "glob" of string`},
+		{`severity == "High"`, `column 10: cannot compare a severity to a string`},
+		{`High == "High"`, `column 1: High must be compared to severity or confidence`},
+		{`severity >= Bogus`, `column 13: unknown field "Bogus"`},
+		{`severity >= Confirmed`, `column 13: invalid severity "Confirmed"`},
+		{`line == "1"`, `column 6: cannot compare a number to a string`},
+		{`name > "a"`, `column 6: > does not apply to a string`},
+		{`identifiers == identifiers`, `column 13: cannot compare lists, use contains`},
+		{`name`, `expression is a string, expected a bool`},
+		{`severity`, `expression is a severity, expected a bool`},
+		{`!name`, `column 1: ! expects a bool, got a string`},
+		{`name && dependency.direct`, `column 6: && expects bools, got a string and a bool`},
+		{`name in name`, `column 6: in expects a string and a list, got a string and a string`},
+		{`file.matches(1)`, `column 6: matches expects a string, got a number`},
+		{`file.matches("[")`, `column 14: invalid pattern "["`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := compileExpr(tt.src)
+			if err == nil {
+				t.Fatalf("expected error %q", tt.wantErr)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExprMatch(t *testing.T) {
+	direct := &Finding{Vulnerability: report.Vulnerability{
+		Category:    report.CategoryDependencyScanning,
+		Name:        "Prototype pollution",
+		Severity:    report.SeverityLevelHigh,
+		Confidence:  report.ConfidenceLevelConfirmed,
+		Identifiers: []report.Identifier{{Type: "cve", Value: "CVE-2021-23337"}},
+		Location: report.Location{
+			File: "package-lock.json",
+			Dependency: &report.Dependency{
+				Package: report.Package{Name: "lodash"},
+				Version: "4.17.20",
+				Direct:  true,
+			},
+		},
+	}}
+	transitiveTest := &Finding{Vulnerability: report.Vulnerability{
+		Category: report.CategoryDependencyScanning,
+		Severity: report.SeverityLevelCritical,
+		Location: report.Location{
+			File:       "test/fixtures/package-lock.json",
+			Dependency: &report.Dependency{Package: report.Package{Name: "minimist"}},
+		},
+	}}
+	sast := &Finding{Vulnerability: report.Vulnerability{
+		Category: report.CategorySast,
+		Location: report.Location{File: "cmd/main.go", LineStart: 42},
+	}}
+
+	example := `category == "dependency_scanning" && severity >= High && dependency.direct && !file.matches("test/**")`
+	tests := []struct {
+		src     string
+		finding *Finding
+		want    bool
+	}{
+		{example, direct, true},
+		{example, transitiveTest, false},
+		{example, sast, false},
+		{`category == "dependency_scanning" && severity >= High && !file.matches("test/**")`, transitiveTest, false},
+		{`category == "dependency_scanning" && severity >= High`, transitiveTest, true},
+
+		{`severity > High`, direct, false},
+		{`severity == Critical`, transitiveTest, true},
+		{`severity == Unknown`, sast, true},
+		{`confidence >= high`, direct, true},
+		{`confidence == Unknown`, transitiveTest, true},
+
+		{`line == 42`, sast, true},
+		{`line >= 40 && line < 50`, sast, true},
+		{`line != 42`, sast, false},
+
+		{`dependency.package.name == "lodash" && dependency.version.startsWith("4.")`, direct, true},
+		{`dependency.package.name == ""`, sast, true},
+		{`dependency.direct`, sast, false},
+
+		{`identifiers.contains("CVE-2021-23337")`, direct, true},
+		{`identifier_types.contains("cwe")`, direct, false},
+		{`"cve" in identifier_types`, direct, true},
+		{`category in ["sast", "secret_detection"]`, sast, true},
+		{`category in ["sast", "secret_detection"]`, direct, false},
+
+		{`file.matches("**/*.json")`, transitiveTest, true},
+		{`file.matches("*.json")`, transitiveTest, false},
+		{`file.endsWith(".go") && file.contains("cmd/")`, sast, true},
+		{`name.contains("pollution") || severity >= Critical`, direct, true},
+		{`!(name.contains("pollution") || severity >= Critical)`, transitiveTest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := compileExpr(tt.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := e.Match(tt.finding); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// configExtendsKey is the configuration key listing the parent configuration files
+const configExtendsKey = "extends"
+
+// lockedKeys are the keys which could loosen an extended policy other than by its severity thresholds:
+// only the extended configurations may set them
+var lockedKeys = []string{
+	"confidence",
+	"confidence-matrix",
+	"exclude",
+	"exclude-preset",
+	"suppressions",
+	"warnings-exit-code",
+	"sast-ruleset",
+	"secret-detection-ruleset",
+	"ruleset-force",
+	"sarif-severity",
+	"sarif-security-severity",
+	"sarif-category",
+	"mapping",
+	"diff-aware",
+	"baseline",
+}
+
+// configPathKeys are the keys holding the paths of policy files, which are relative to the
+// extended configuration declaring them rather than to the working directory
+var configPathKeys = []string{"rules", "mapping", "suppressions", "sast-ruleset", "secret-detection-ruleset"}
+
+// floor is what the extended policies impose on the layers extending them
+type floor struct {
+	thresholds          // thresholds are the tightest severity thresholds of the extended policies
+	policies   []string // policies are the extended configuration files
+	rules      []string // rules are the rule files of the extended policies, which may go below the thresholds
+}
+
+// loadConfigFile reads a configuration file and the files it extends, the parents being merged first.
+// It returns the merged values, keyed by flag name, and the floor set by the parents,
+// which neither the file nor any later layer may loosen.
+func loadConfigFile(path, policyDir string, chain []string) (map[string]interface{}, floor, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, floor{}, err
+	}
+	for _, p := range chain {
+		if p == abs {
+			return nil, floor{}, fmt.Errorf("%s: circular extends", path)
+		}
+	}
+	extended := len(chain) > 0
+	chain = append(chain, abs)
+
+	file, err := readConfigFile(path)
+	if err != nil {
+		return nil, floor{}, err
+	}
+
+	values := make(map[string]interface{})
+	for key, value := range file {
+		values[normalizeConfigKey(key)] = value
+	}
+
+	var parents []string
+	if extends, ok := values[configExtendsKey]; ok {
+		if parents, err = configValues(extends); err != nil {
+			return nil, floor{}, fmt.Errorf("%s: %s: %w", path, configExtendsKey, err)
+		}
+		delete(values, configExtendsKey)
+	}
+	if extended {
+		if err := resolveConfigPaths(values, filepath.Dir(path)); err != nil {
+			return nil, floor{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if len(parents) > 0 {
+		for _, key := range lockedKeys {
+			if _, ok := values[key]; ok {
+				return nil, floor{}, fmt.Errorf("%s: %s cannot be set by a configuration extending another policy", path, key)
+			}
+		}
+	}
+
+	merged := make(map[string]interface{})
+	var f floor
+	for _, parent := range parents {
+		parentPath, err := resolveExtends(parent, filepath.Dir(path), policyDir)
+		if err != nil {
+			return nil, floor{}, fmt.Errorf("%s: %s: %w", path, configExtendsKey, err)
+		}
+		parentValues, parentFloor, err := loadConfigFile(parentPath, policyDir, chain)
+		if err != nil {
+			return nil, floor{}, err
+		}
+		mergeConfig(merged, parentValues)
+
+		own, err := thresholdsOf(parentValues)
+		if err != nil {
+			return nil, floor{}, fmt.Errorf("%s: %w", parentPath, err)
+		}
+		f.thresholds = f.thresholds.tightest(parentFloor.thresholds).tightest(own)
+		f.policies = append(append(f.policies, parentFloor.policies...), parentPath)
+		if value, ok := parentValues["rules"]; ok {
+			rules, err := configValues(value)
+			if err != nil {
+				return nil, floor{}, fmt.Errorf("%s: rules: %w", parentPath, err)
+			}
+			f.rules = append(f.rules, rules...)
+		}
+	}
+	mergeConfig(merged, values)
+
+	effective, err := thresholdsOf(merged)
+	if err != nil {
+		return nil, floor{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := f.check(effective); err != nil {
+		return nil, floor{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return merged, f, nil
+}
+
+// resolveExtends locates a parent configuration, relative paths being looked up next to the child
+// and then in the policy directory. A directory stands for the default configuration file in it.
+func resolveExtends(parent, dir, policyDir string) (string, error) {
+	candidates := []string{parent}
+	if !filepath.IsAbs(parent) {
+		candidates = []string{filepath.Join(dir, parent)}
+		if policyDir != "" {
+			candidates = append(candidates, filepath.Join(policyDir, parent))
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			return candidate, nil
+		}
+		for _, name := range defaultConfigPaths {
+			path := filepath.Join(candidate, filepath.Base(name))
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+		return "", fmt.Errorf("no configuration file in directory %s", candidate)
+	}
+	return "", fmt.Errorf("%s not found", parent)
+}
+
+// resolveConfigPaths joins the relative paths of the path-valued keys of a configuration to its directory
+func resolveConfigPaths(values map[string]interface{}, dir string) error {
+	for _, key := range configPathKeys {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		list, err := configValues(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		resolved := make([]interface{}, len(list))
+		for i, p := range list {
+			resolved[i] = resolveConfigPath(p, dir)
+		}
+		if _, isList := value.([]interface{}); isList {
+			values[key] = resolved
+		} else {
+			values[key] = resolved[0]
+		}
+	}
+	return nil
+}
+
+// resolveConfigPath returns a path relative to dir, unless it is absolute
+func resolveConfigPath(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// mergeConfig merges src into dst: maps are merged key by key, lists and the values of repeatable flags
+// are appended and other values replaced
+func mergeConfig(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if previous, ok := dst[key]; ok && repeatableFlags[key] {
+			dst[key] = append(configList(previous), configList(value)...)
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if m, ok := dst[key].(map[string]interface{}); ok {
+				merged := make(map[string]interface{}, len(m)+len(v))
+				for k, x := range m {
+					merged[k] = x
+				}
+				for k, x := range v {
+					merged[k] = x
+				}
+				dst[key] = merged
+				continue
+			}
+		case []interface{}:
+			if list, ok := dst[key].([]interface{}); ok {
+				dst[key] = append(append([]interface{}{}, list...), v...)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// configList returns a configuration value as a list
+func configList(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return append([]interface{}{}, list...)
+	}
+	return []interface{}{value}
+}
+
+// thresholds are the severity thresholds explicitly set by a configuration
+type thresholds struct {
+	global     *report.SeverityLevel
+	categories map[report.Category]report.SeverityLevel
+}
+
+// thresholdsOf parses the severity and category-severity values of a configuration
+func thresholdsOf(values map[string]interface{}) (thresholds, error) {
+	var t thresholds
+	if value, ok := values["severity"]; ok {
+		list, err := configValues(value)
+		if err != nil {
+			return t, fmt.Errorf("severity: %w", err)
+		}
+		if len(list) != 1 {
+			return t, fmt.Errorf("severity: expected a single value")
+		}
+		level, err := parseSeverity(list[0])
+		if err != nil {
+			return t, fmt.Errorf("severity: %w", err)
+		}
+		t.global = &level
+	}
+	if value, ok := values["category-severity"]; ok {
+		list, err := configValues(value)
+		if err != nil {
+			return t, fmt.Errorf("category-severity: %w", err)
+		}
+		t.categories = make(map[report.Category]report.SeverityLevel)
+		for _, pair := range list {
+			if err := categorySeverityFlag(t.categories).Set(pair); err != nil {
+				return t, fmt.Errorf("category-severity: %w", err)
+			}
+		}
+	}
+	return t, nil
+}
+
+// policyThresholds returns the thresholds of a policy
+func policyThresholds(p *Policy) thresholds {
+	level := p.Threshold
+	return thresholds{global: &level, categories: p.CategoryThresholds}
+}
+
+// limit returns the threshold set for a category, if any
+func (t thresholds) limit(category report.Category) (report.SeverityLevel, bool) {
+	if level, ok := t.categories[category]; ok {
+		return level, true
+	}
+	if t.global != nil {
+		return *t.global, true
+	}
+	return report.SeverityLevelUndefined, false
+}
+
+// effective returns the threshold applied to a category, Info when none is set
+func (t thresholds) effective(category report.Category) report.SeverityLevel {
+	if level, ok := t.limit(category); ok {
+		return level
+	}
+	return report.SeverityLevelInfo
+}
+
+// tightest combines two floors, keeping the lowest threshold of each
+func (t thresholds) tightest(other thresholds) thresholds {
+	out := thresholds{global: t.global, categories: make(map[report.Category]report.SeverityLevel)}
+	if other.global != nil && (out.global == nil || *other.global < *out.global) {
+		out.global = other.global
+	}
+	for _, src := range []thresholds{t, other} {
+		for category := range src.categories {
+			for _, limits := range []thresholds{t, other} {
+				level, ok := limits.limit(category)
+				if current, set := out.categories[category]; ok && (!set || level < current) {
+					out.categories[category] = level
+				}
+			}
+		}
+	}
+	return out
+}
+
+// check returns an error when t, the floor, is looser in any category than the thresholds of child.
+// A child may only lower a threshold, failing the gate on more findings.
+func (t thresholds) check(child thresholds) error {
+	if t.global != nil {
+		if level := child.effective(""); level > *t.global {
+			return fmt.Errorf("severity threshold %s is looser than %s set by the extended policy", level, *t.global)
+		}
+	}
+
+	var categories []string
+	for category := range t.categories {
+		categories = append(categories, string(category))
+	}
+	for category := range child.categories {
+		categories = append(categories, string(category))
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		parent, ok := t.limit(report.Category(category))
+		if !ok {
+			continue
+		}
+		if level := child.effective(report.Category(category)); level > parent {
+			return fmt.Errorf("%s severity threshold %s is looser than %s set by the extended policy", category, level, parent)
+		}
+	}
+	return nil
+}
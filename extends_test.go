@@ -0,0 +1,195 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+func severity(level report.SeverityLevel) *report.SeverityLevel {
+	return &level
+}
+
+func TestThresholdsTightest(t *testing.T) {
+	tests := []struct {
+		name    string
+		parents []thresholds
+		want    thresholds
+	}{
+		{
+			name:    "single parent",
+			parents: []thresholds{{global: severity(report.SeverityLevelHigh)}},
+			want:    thresholds{global: severity(report.SeverityLevelHigh)},
+		},
+		{
+			name: "lowest global",
+			parents: []thresholds{
+				{global: severity(report.SeverityLevelCritical)},
+				{global: severity(report.SeverityLevelMedium)},
+			},
+			want: thresholds{global: severity(report.SeverityLevelMedium)},
+		},
+		{
+			name: "global tighter than category",
+			parents: []thresholds{
+				{global: severity(report.SeverityLevelHigh)},
+				{categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelCritical}},
+			},
+			want: thresholds{
+				global:     severity(report.SeverityLevelHigh),
+				categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelHigh},
+			},
+		},
+		{
+			name: "category tighter than global",
+			parents: []thresholds{
+				{global: severity(report.SeverityLevelCritical)},
+				{categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium}},
+			},
+			want: thresholds{
+				global:     severity(report.SeverityLevelCritical),
+				categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium},
+			},
+		},
+		{
+			name: "lowest category",
+			parents: []thresholds{
+				{categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium}},
+				{categories: map[report.Category]report.SeverityLevel{
+					report.CategorySast:            report.SeverityLevelHigh,
+					report.CategorySecretDetection: report.SeverityLevelLow,
+				}},
+			},
+			want: thresholds{categories: map[report.Category]report.SeverityLevel{
+				report.CategorySast:            report.SeverityLevelMedium,
+				report.CategorySecretDetection: report.SeverityLevelLow,
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got thresholds
+			for _, parent := range tt.parents {
+				got = got.tightest(parent)
+			}
+
+			if (got.global == nil) != (tt.want.global == nil) || (got.global != nil && *got.global != *tt.want.global) {
+				t.Errorf("global: got %v, want %v", got.global, tt.want.global)
+			}
+			if len(got.categories) != len(tt.want.categories) {
+				t.Errorf("categories: got %v, want %v", got.categories, tt.want.categories)
+			}
+			for category, want := range tt.want.categories {
+				if level, ok := got.categories[category]; !ok || level != want {
+					t.Errorf("%s: got %v, want %v", category, level, want)
+				}
+			}
+		})
+	}
+}
+
+func TestThresholdsCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		parents []thresholds
+		child   thresholds
+		wantErr string
+	}{
+		{
+			name:    "child tightens global",
+			parents: []thresholds{{global: severity(report.SeverityLevelHigh)}},
+			child:   thresholds{global: severity(report.SeverityLevelMedium)},
+		},
+		{
+			name:    "child loosens global",
+			parents: []thresholds{{global: severity(report.SeverityLevelHigh)}},
+			child:   thresholds{global: severity(report.SeverityLevelCritical)},
+			wantErr: "severity threshold Critical is looser than High set by the extended policy",
+		},
+		{
+			name:    "parent global, child tightens category",
+			parents: []thresholds{{global: severity(report.SeverityLevelHigh)}},
+			child: thresholds{
+				global:     severity(report.SeverityLevelHigh),
+				categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelLow},
+			},
+		},
+		{
+			name:    "parent global, child loosens category",
+			parents: []thresholds{{global: severity(report.SeverityLevelHigh)}},
+			child: thresholds{
+				global:     severity(report.SeverityLevelHigh),
+				categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelCritical},
+			},
+			wantErr: "sast severity threshold Critical is looser than High set by the extended policy",
+		},
+		{
+			name:    "parent category, child tightens global",
+			parents: []thresholds{{categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium}}},
+			child:   thresholds{global: severity(report.SeverityLevelLow)},
+		},
+		{
+			name:    "parent category, child loosens global",
+			parents: []thresholds{{categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium}}},
+			child:   thresholds{global: severity(report.SeverityLevelHigh)},
+			wantErr: "sast severity threshold High is looser than Medium set by the extended policy",
+		},
+		{
+			name:    "parent category, child global with tighter category",
+			parents: []thresholds{{categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium}}},
+			child: thresholds{
+				global:     severity(report.SeverityLevelCritical),
+				categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium},
+			},
+		},
+		{
+			name: "multiple parents, child within both",
+			parents: []thresholds{
+				{global: severity(report.SeverityLevelCritical)},
+				{categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium}},
+			},
+			child: thresholds{
+				global:     severity(report.SeverityLevelHigh),
+				categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelLow},
+			},
+		},
+		{
+			name: "multiple parents, child loosens the category of one",
+			parents: []thresholds{
+				{global: severity(report.SeverityLevelCritical)},
+				{categories: map[report.Category]report.SeverityLevel{report.CategorySast: report.SeverityLevelMedium}},
+			},
+			child:   thresholds{global: severity(report.SeverityLevelHigh)},
+			wantErr: "sast severity threshold High is looser than Medium set by the extended policy",
+		},
+		{
+			name: "multiple parents, child loosens the global of the other",
+			parents: []thresholds{
+				{global: severity(report.SeverityLevelCritical)},
+				{global: severity(report.SeverityLevelHigh)},
+			},
+			child:   thresholds{global: severity(report.SeverityLevelCritical)},
+			wantErr: "severity threshold Critical is looser than High set by the extended policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var floor thresholds
+			for _, parent := range tt.parents {
+				floor = floor.tightest(parent)
+			}
+
+			err := floor.check(tt.child)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("expected error %q", tt.wantErr)
+			case tt.wantErr != "" && err.Error() != tt.wantErr:
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import "gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+
+// Decision is the outcome of the gate for a finding
+type Decision int
+
+const (
+	// DecisionFail fails the gate
+	DecisionFail Decision = iota
+	// DecisionWarn reports the finding without failing the gate
+	DecisionWarn
+	// DecisionSuppress accepts the finding through a suppression
+	DecisionSuppress
+	// DecisionIgnore drops the finding from the gate through a policy rule
+	DecisionIgnore
+)
+
+func (d Decision) String() string {
+	switch d {
+	case DecisionFail:
+		return "fail"
+	case DecisionWarn:
+		return "warn"
+	case DecisionSuppress:
+		return "suppress"
+	case DecisionIgnore:
+		return "ignore"
+	}
+	return ""
+}
+
+// Finding is a vulnerability along with the report it was read from
+type Finding struct {
+	report.Vulnerability
+	Source   string   // Source is the path of the report the finding was read from
+	Decision Decision // Decision is the outcome of the gate for this finding
+	Reason   string   // Reason explains the decision
+
+	Suppression *Suppression // Suppression is the allowlist entry accepting the finding, if any
+	Existing    bool         // Existing is true when the finding is present in the baseline
+}
+
+// Decide records the outcome of the gate for the finding
+func (f *Finding) Decide(d Decision, reason string) {
+	f.Decision = d
+	f.Reason = reason
+}
+
+// ReportResult holds a parsed report and the findings extracted from it
+type ReportResult struct {
+	Path     string
+	Report   report.Report
+	Findings []*Finding
+	Merged   []string // Merged lists the source reports combined into this one, if any
+}
+
+// Result is the combined verdict across every report
+type Result struct {
+	Reports []*ReportResult
+}
+
+// Add registers a report with the result, tagging every vulnerability with its category.
+// Vulnerabilities lacking a category inherit the scan type, falling back to the report filename.
+func (res *Result) Add(path string, r report.Report) *ReportResult {
+	category := reportCategory(path, r)
+
+	rr := &ReportResult{Path: path, Report: r}
+	for _, v := range r.Vulnerabilities {
+		if v.Category == "" {
+			v.Category = category
+		}
+		rr.Findings = append(rr.Findings, &Finding{Vulnerability: v, Source: path})
+	}
+
+	res.Reports = append(res.Reports, rr)
+	return rr
+}
+
+// reportCategory returns the scan type of a report, falling back to the category in its filename
+func reportCategory(path string, r report.Report) report.Category {
+	if r.Scan.Type != "" {
+		return r.Scan.Type
+	}
+	return report.Category(categoryFromFilename(path))
+}
+
+// Findings returns the findings of every report
+func (res *Result) Findings() []*Finding {
+	var findings []*Finding
+	for _, rr := range res.Reports {
+		findings = append(findings, rr.Findings...)
+	}
+	return findings
+}
+
+// Filter returns the findings with the given decision
+func (res *Result) Filter(d Decision) []*Finding {
+	var findings []*Finding
+	for _, rr := range res.Reports {
+		findings = append(findings, rr.Filter(d)...)
+	}
+	return findings
+}
+
+// Filter returns the findings of the report with the given decision
+func (rr *ReportResult) Filter(d Decision) []*Finding {
+	var findings []*Finding
+	for _, f := range rr.Findings {
+		if f.Decision == d {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// Category returns the category of the report
+func (rr *ReportResult) Category() report.Category {
+	if rr.Report.Scan.Type != "" {
+		return rr.Report.Scan.Type
+	}
+	if len(rr.Findings) > 0 {
+		return rr.Findings[0].Category
+	}
+	return report.Category(categoryFromFilename(rr.Path))
+}
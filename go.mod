@@ -3,13 +3,15 @@ module gitlab-security-report-gate
 go 1.17
 
 require (
+	github.com/bmatcuk/doublestar/v4 v4.6.1
+	github.com/pelletier/go-toml v1.8.1
 	github.com/sirupsen/logrus v1.7.0
 	gitlab.com/gitlab-org/security-products/analyzers/report/v2 v2.1.0
+	gitlab.com/gitlab-org/security-products/analyzers/ruleset v1.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/pelletier/go-toml v1.8.1 // indirect
 	gitlab.com/gitlab-org/security-products/analyzers/common/v2 v2.22.1 // indirect
-	gitlab.com/gitlab-org/security-products/analyzers/ruleset v1.0.0 // indirect
 	golang.org/x/sys v0.0.0-20200915084602-288bc346aa39 // indirect
 )
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// JUnitTestSuites is the root element of a JUnit XML report
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite groups the findings of a category
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is a single finding
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *JUnitMessage `xml:"failure,omitempty"`
+	Skipped   *JUnitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// JUnitMessage is the failure or skipped element of a test case
+type JUnitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the gate decisions as a JUnit XML report, one test suite per category.
+// Failing findings are failures, warnings and suppressed findings are skipped.
+func writeJUnit(filename string, res *Result, redactor Redactor) error {
+	byCategory := make(map[report.Category][]*Finding)
+	for _, f := range res.Findings() {
+		byCategory[f.Category] = append(byCategory[f.Category], f)
+	}
+	for _, rr := range res.Reports {
+		if _, ok := byCategory[rr.Category()]; !ok {
+			byCategory[rr.Category()] = nil
+		}
+	}
+
+	var categories []string
+	for category := range byCategory {
+		categories = append(categories, string(category))
+	}
+	sort.Strings(categories)
+
+	suites := JUnitTestSuites{Name: "gitlab-security-report-gate"}
+	for _, category := range categories {
+		findings := byCategory[report.Category(category)]
+		sortFindings(findings)
+
+		suite := JUnitTestSuite{Name: category}
+		for _, f := range findings {
+			v := redactor.Vulnerability(f.Vulnerability)
+			tc := JUnitTestCase{
+				Name:      fmt.Sprintf("%s at %s", v.Name, locationString(v.Location)),
+				ClassName: fmt.Sprintf("%s.%s", category, primaryIdentifier(v)),
+				File:      v.Location.File,
+				SystemOut: junitDetails(f, v),
+			}
+
+			msg := &JUnitMessage{
+				Message: fmt.Sprintf("[%s] %s", severityOf(v), f.Reason),
+				Type:    severityOf(v).String(),
+				Text:    v.Message,
+			}
+			switch f.Decision {
+			case DecisionFail:
+				tc.Failure = msg
+				suite.Failures++
+			default:
+				msg.Message = fmt.Sprintf("%s: %s", f.Decision, msg.Message)
+				tc.Skipped = msg
+				suite.Skipped++
+			}
+
+			suite.TestCases = append(suite.TestCases, tc)
+			suite.Tests++
+		}
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	b, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append([]byte(xml.Header), append(b, '\n')...), 0644)
+}
+
+func junitDetails(f *Finding, v report.Vulnerability) string {
+	var lines []string
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s", label, value))
+		}
+	}
+	add("ID", f.ID())
+	add("Report", f.Source)
+	add("Scanner", v.Scanner.Name)
+	add("Confidence", confidenceOf(v).String())
+	add("Description", v.Description)
+	add("Solution", v.Solution)
+	for _, id := range v.Identifiers {
+		add("Identifier", id.Name)
+	}
+	for _, l := range v.Links {
+		add("Link", l.URL)
+	}
+	return strings.Join(lines, "\n")
+}
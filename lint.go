@@ -0,0 +1,657 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// knownIdentifierTypes are the identifier types of report.IdentifierType and those set by the adapters
+var knownIdentifierTypes = []report.IdentifierType{
+	report.IdentifierTypeCVE,
+	report.IdentifierTypeCWE,
+	report.IdentifierTypeOSVDB,
+	report.IdentifierTypeUSN,
+	report.IdentifierTypeWASC,
+	report.IdentifierTypeRHSA,
+	report.IdentifierTypeELSA,
+	report.IdentifierTypeH1,
+	"gitleaks_rule_id",
+	"trufflehog_detector",
+}
+
+// identifierTypeSlug is the format of identifier types, scanner specific types ending with _id
+var identifierTypeSlug = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Diagnostic is a problem found in a policy file
+type Diagnostic struct {
+	File    string
+	Line    int
+	Warning bool // Warning diagnostics do not fail the lint
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	level := "error"
+	if d.Warning {
+		level = "warning"
+	}
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, level, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, level, d.Message)
+}
+
+// linter checks configuration, suppression, rule and mapping files
+type linter struct {
+	flags     *flag.FlagSet
+	policyDir string
+	now       time.Time
+
+	diagnostics []Diagnostic
+	queue       []string
+	done        map[string]bool
+	extended    map[string]bool // extended are the configurations queued as the parent of another
+}
+
+// runLint implements the lint subcommand. Without arguments it checks the configuration and
+// suppression files the gate would read, along with the rule, mapping and parent files they refer to.
+func runLint(args []string, gateFlags *flag.FlagSet, stdout io.Writer) ExitCode {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	policyDir := fs.String("policy-dir", os.Getenv(envName("policy-dir")), "Directory of the policies extended by relative paths")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s lint [-policy-dir dir] [files]\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Checks configuration, suppression, rule and mapping files, by default %s and %s.\n", defaultConfigPaths[0], defaultSuppressionsPath)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return ExitConfig
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		if path := os.Getenv(envName("config")); path != "" {
+			files = append(files, path)
+		} else {
+			for _, path := range defaultConfigPaths {
+				if _, err := os.Stat(path); err == nil {
+					files = append(files, path)
+					break
+				}
+			}
+		}
+		if path := os.Getenv(envName("suppressions")); path != "" {
+			files = append(files, path)
+		} else if _, err := os.Stat(defaultSuppressionsPath); err == nil {
+			files = append(files, defaultSuppressionsPath)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(stdout, "No policy files to lint")
+		return ExitConfig
+	}
+
+	l := &linter{flags: gateFlags, policyDir: *policyDir, now: time.Now(), done: make(map[string]bool), extended: make(map[string]bool)}
+	l.queue = files
+	for len(l.queue) > 0 {
+		path := l.queue[0]
+		l.queue = l.queue[1:]
+		if l.done[filepath.Clean(path)] {
+			continue
+		}
+		l.done[filepath.Clean(path)] = true
+		l.lintFile(path)
+	}
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+
+	errors, warnings := 0, 0
+	for _, d := range l.diagnostics {
+		fmt.Fprintln(stdout, d)
+		if d.Warning {
+			warnings++
+		} else {
+			errors++
+		}
+	}
+	fmt.Fprintf(stdout, "%d files checked, %d errors, %d warnings\n", len(l.done), errors, warnings)
+	if errors > 0 {
+		return ExitConfig
+	}
+	return ExitPass
+}
+
+func (l *linter) errorf(file string, line int, format string, args ...interface{}) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(file string, line int, format string, args ...interface{}) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{File: file, Line: line, Warning: true, Message: fmt.Sprintf(format, args...)})
+}
+
+// lintFile checks a file according to its content: suppressions, rules, a mapping or a configuration
+func (l *linter) lintFile(path string) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		l.errorf(path, 0, "%s", err)
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		l.lintTOMLConfig(path, b)
+		return
+	}
+
+	// JSON is parsed as YAML too, for the line numbers of the nodes
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		l.errorf(path, 0, "%s", err)
+		return
+	}
+	if len(doc.Content) == 0 {
+		l.warnf(path, 0, "empty file")
+		return
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		l.errorf(path, root.Line, "expected a mapping at the top level")
+		return
+	}
+
+	switch {
+	case yamlValue(root, "suppressions") != nil && yamlValue(root, "suppressions").Kind == yaml.SequenceNode:
+		l.lintSuppressions(path, root)
+	case yamlValue(root, "rules") != nil && yamlValue(root, "rules").Kind == yaml.SequenceNode &&
+		len(yamlValue(root, "rules").Content) > 0 && yamlValue(root, "rules").Content[0].Kind == yaml.MappingNode:
+		l.lintRules(path, root)
+	case yamlValue(root, "results") != nil:
+		l.lintMapping(path, root)
+	default:
+		l.lintConfigValues(path, root, nil)
+	}
+}
+
+// lintConfigValues checks the keys and values of a configuration, given as a YAML mapping or,
+// when root is nil, decoded from the file with the lines found in lines
+func (l *linter) lintConfigValues(path string, root *yaml.Node, lines map[string]int) {
+	values := make(map[string]interface{})
+	if root != nil {
+		lines = make(map[string]int)
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+			var v interface{}
+			if err := value.Decode(&v); err != nil {
+				l.errorf(path, value.Line, "%s: %s", key.Value, err)
+				continue
+			}
+			values[key.Value] = v
+			lines[key.Value] = key.Line
+		}
+	} else {
+		var err error
+		if values, err = readConfigFile(path); err != nil {
+			l.errorf(path, 0, "%s", err)
+			return
+		}
+	}
+
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name, line := normalizeConfigKey(key), lines[key]
+		list, err := configValues(values[key])
+		if err != nil {
+			l.errorf(path, line, "%s: %s", key, err)
+			continue
+		}
+
+		switch name {
+		case configExtendsKey:
+			for _, parent := range list {
+				parentPath, err := resolveExtends(parent, filepath.Dir(path), l.policyDir)
+				if err != nil {
+					l.errorf(path, line, "extends: %s", err)
+					continue
+				}
+				l.queue = append(l.queue, parentPath)
+				l.extended[filepath.Clean(parentPath)] = true
+			}
+			continue
+		case configReportsKey:
+			continue
+		case "rules", "mapping", "suppressions":
+			// linted as files of their own, with their own line numbers
+			for _, p := range list {
+				if l.extended[filepath.Clean(path)] {
+					p = resolveConfigPath(p, filepath.Dir(path))
+				}
+				l.queue = append(l.queue, p)
+			}
+			continue
+		}
+
+		f := l.flags.Lookup(name)
+		if f == nil {
+			l.errorf(path, line, "unknown key %q", key)
+			continue
+		}
+		for _, value := range list {
+			if err := f.Value.Set(value); err != nil {
+				l.errorf(path, line, "%s: %s", key, err)
+			}
+		}
+	}
+
+	// the thresholds of the extended policies must not be loosened
+	if _, ok := values[configExtendsKey]; ok {
+		if _, _, err := loadConfigFile(path, l.policyDir, nil); err != nil {
+			l.errorf(path, lines[configExtendsKey], "%s", strings.TrimPrefix(err.Error(), path+": "))
+		}
+	}
+}
+
+// lintTOMLConfig checks a TOML configuration, locating keys with the positions of the TOML tree
+func (l *linter) lintTOMLConfig(path string, b []byte) {
+	tree, err := toml.LoadBytes(b)
+	if err != nil {
+		l.errorf(path, 0, "%s", err)
+		return
+	}
+	lines := make(map[string]int)
+	for _, key := range tree.Keys() {
+		lines[key] = tree.GetPosition(key).Line
+	}
+	l.lintConfigValues(path, nil, lines)
+}
+
+// suppressionKeys are the keys of a suppression entry
+var suppressionKeys = []string{"id", "compare_key", "identifier", "path", "justification", "approver", "expires"}
+
+// lintSuppressions checks the entries of a suppression file, reporting expired and unreachable suppressions
+func (l *linter) lintSuppressions(path string, root *yaml.Node) {
+	l.unknownKeys(path, root, []string{"suppressions"}, "")
+
+	list := yamlValue(root, "suppressions")
+	type entry struct {
+		s    *Suppression
+		line int
+	}
+	var valid []entry
+	for i, node := range list.Content {
+		l.unknownKeys(path, node, suppressionKeys, fmt.Sprintf("suppression #%d: ", i+1))
+
+		s := &Suppression{}
+		if err := node.Decode(s); err != nil {
+			l.errorf(path, node.Line, "suppression #%d: %s", i+1, err)
+			continue
+		}
+		if err := s.validate(); err != nil {
+			l.errorf(path, node.Line, "suppression #%d: %s", i+1, err)
+			continue
+		}
+		if s.Expired(l.now) {
+			l.errorf(path, yamlLine(node, "expires"), "suppression #%d expired on %s", i+1, s.Expires)
+			continue
+		}
+
+		for _, earlier := range valid {
+			if suppressionCovers(earlier.s, s) {
+				l.warnf(path, node.Line, "suppression #%d is unreachable, suppression at line %d matches the same findings until %s", i+1, earlier.line, earlier.s.Expires)
+				break
+			}
+		}
+		valid = append(valid, entry{s: s, line: node.Line})
+	}
+}
+
+// suppressionCovers is true when every finding matched by later is matched by earlier for as long as later applies.
+// Only matchers equal on both sides are compared, patterns are not expanded.
+func suppressionCovers(earlier, later *Suppression) bool {
+	if earlier.expires.Before(later.expires) {
+		return false
+	}
+	matchers := [][2]string{
+		{earlier.ID, later.ID},
+		{earlier.CompareKey, later.CompareKey},
+		{earlier.Identifier, later.Identifier},
+		{earlier.Path, later.Path},
+	}
+	for _, m := range matchers {
+		if m[0] != "" && m[0] != m[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleKeys are the keys of a rule entry
+var ruleKeys = []string{"name", "when", "decision"}
+
+// lintRules checks the rules of a rule file, reporting the rules an earlier rule always decides for
+// and the rules overlapping an earlier one with a different decision
+func (l *linter) lintRules(path string, root *yaml.Node) {
+	l.unknownKeys(path, root, []string{"rules"}, "")
+
+	type entry struct {
+		r    *Rule
+		line int
+		c    constraints
+	}
+	var compiled []entry
+	names := make(map[string]int)
+	for i, node := range yamlValue(root, "rules").Content {
+		l.unknownKeys(path, node, ruleKeys, fmt.Sprintf("rule #%d: ", i+1))
+
+		r := &Rule{}
+		if err := node.Decode(r); err != nil {
+			l.errorf(path, node.Line, "rule #%d: %s", i+1, err)
+			continue
+		}
+		if err := r.compile(); err != nil {
+			line := node.Line
+			if r.expr == nil && r.When != "" && strings.HasPrefix(err.Error(), r.Name+": ") {
+				line = yamlLine(node, "when")
+			}
+			l.errorf(path, line, "rule #%d: %s", i+1, err)
+			continue
+		}
+		if first, ok := names[r.Name]; ok {
+			l.warnf(path, node.Line, "rule name %q already used at line %d", r.Name, first)
+		} else {
+			names[r.Name] = node.Line
+		}
+
+		for _, lit := range identifierTypeLiterals(r.expr.root) {
+			l.checkIdentifierType(path, yamlLine(node, "when"), lit)
+		}
+
+		c := constraintsOf(r.expr.root)
+		if c.empty() {
+			l.errorf(path, yamlLine(node, "when"), "rule %q never matches, its conditions contradict each other", r.Name)
+			continue
+		}
+		for _, earlier := range compiled {
+			if earlier.c.covers(c) {
+				l.errorf(path, node.Line, "rule %q is unreachable, rule %q at line %d matches every finding it matches", r.Name, earlier.r.Name, earlier.line)
+				break
+			}
+			if earlier.r.decision != r.decision && !c.covers(earlier.c) && earlier.c.overlaps(c) {
+				l.warnf(path, node.Line, "rule %q overlaps rule %q at line %d, which decides %s where both match", r.Name, earlier.r.Name, earlier.line, earlier.r.decision)
+			}
+		}
+		compiled = append(compiled, entry{r: r, line: node.Line, c: c})
+	}
+}
+
+// mappingKeys are the keys of a mapping file, mappingFieldKeys those of its fields
+var (
+	mappingKeys           = []string{"name", "category", "files", "results", "fields", "severity_map"}
+	mappingFieldKeys      = []string{"name", "message", "description", "solution", "severity", "confidence", "compare_key", "file", "line_start", "line_end", "identifiers"}
+	mappingIdentifierKeys = []string{"type", "value", "name"}
+)
+
+// lintMapping checks the keys, identifier types and severities of a mapping file
+func (l *linter) lintMapping(path string, root *yaml.Node) {
+	l.unknownKeys(path, root, mappingKeys, "")
+	if fields := yamlValue(root, "fields"); fields != nil {
+		l.unknownKeys(path, fields, mappingFieldKeys, "fields: ")
+		if ids := yamlValue(fields, "identifiers"); ids != nil {
+			for i, id := range ids.Content {
+				l.unknownKeys(path, id, mappingIdentifierKeys, fmt.Sprintf("fields: identifiers #%d: ", i+1))
+				if t := yamlValue(id, "type"); t != nil {
+					l.checkIdentifierType(path, t.Line, t.Value)
+				}
+			}
+		}
+	}
+	if severities := yamlValue(root, "severity_map"); severities != nil {
+		for i := 0; i+1 < len(severities.Content); i += 2 {
+			value := severities.Content[i+1]
+			if _, err := parseSeverity(value.Value); err != nil {
+				l.errorf(path, value.Line, "severity_map: %s: %s", severities.Content[i].Value, err)
+			}
+		}
+	}
+
+	if _, err := loadMapping(path); err != nil && !strings.Contains(err.Error(), "severity_map") {
+		l.errorf(path, 0, "%s", strings.TrimPrefix(err.Error(), path+": "))
+	}
+}
+
+// checkIdentifierType reports identifier types that are not slugs, and warns about unknown ones
+// which are not scanner specific (ending with _id)
+func (l *linter) checkIdentifierType(path string, line int, t string) {
+	if !identifierTypeSlug.MatchString(t) {
+		l.errorf(path, line, "invalid identifier type %q, expected a lowercase slug such as %s", t, report.IdentifierTypeCVE)
+		return
+	}
+	for _, known := range knownIdentifierTypes {
+		if report.IdentifierType(t) == known {
+			return
+		}
+	}
+	if !strings.HasSuffix(t, "_id") {
+		l.warnf(path, line, "unknown identifier type %q, neither a report identifier type nor a scanner specific <scanner>_id", t)
+	}
+}
+
+// unknownKeys reports the keys of a YAML mapping missing from allowed
+func (l *linter) unknownKeys(path string, node *yaml.Node, allowed []string, context string) {
+	if node.Kind != yaml.MappingNode {
+		l.errorf(path, node.Line, "%sexpected a mapping", context)
+		return
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !containsString(allowed, key.Value) {
+			l.errorf(path, key.Line, "%sunknown key %q, expected one of %s", context, key.Value, strings.Join(allowed, ", "))
+		}
+	}
+}
+
+// yamlValue returns the value of a key of a YAML mapping
+func yamlValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlLine returns the line of a key of a YAML mapping, or the line of the mapping
+func yamlLine(node *yaml.Node, key string) int {
+	if v := yamlValue(node, key); v != nil {
+		return v.Line
+	}
+	return node.Line
+}
+
+// identifierTypeLiterals returns the strings an expression looks up in identifier_types
+func identifierTypeLiterals(n exprNode) []string {
+	var literals []string
+	var walk func(n exprNode)
+	walk = func(n exprNode) {
+		switch n := n.(type) {
+		case *notNode:
+			walk(n.x)
+		case *binaryNode:
+			if ident, ok := n.y.(*identNode); ok && n.op == "in" && ident.name == "identifier_types" {
+				if lit, ok := n.x.(*literalNode); ok {
+					literals = append(literals, lit.value.(string))
+				}
+			}
+			walk(n.x)
+			walk(n.y)
+		case *callNode:
+			if ident, ok := n.recv.(*identNode); ok && ident.name == "identifier_types" {
+				if lit, ok := n.args[0].(*literalNode); ok {
+					literals = append(literals, lit.value.(string))
+				}
+			}
+		}
+	}
+	walk(n)
+	return literals
+}
+
+// interval is the range of values an ordered field is constrained to, bounds included
+type interval struct {
+	lo, hi float64
+}
+
+// constraints are the conditions of a rule joined with &&: ranges of ordered fields
+// (severity, confidence and numbers) and the text of every other condition
+type constraints struct {
+	ranges map[string]interval
+	others map[string]bool
+}
+
+// constraintsOf splits an expression on && into constraints
+func constraintsOf(n exprNode) constraints {
+	c := constraints{ranges: make(map[string]interval), others: make(map[string]bool)}
+	var split func(n exprNode)
+	split = func(n exprNode) {
+		if b, ok := n.(*binaryNode); ok && b.op == "&&" {
+			split(b.x)
+			split(b.y)
+			return
+		}
+		if field, r, ok := rangeOf(n); ok {
+			current, set := c.ranges[field]
+			if !set {
+				current = interval{lo: math.Inf(-1), hi: math.Inf(1)}
+			}
+			c.ranges[field] = interval{lo: math.Max(current.lo, r.lo), hi: math.Min(current.hi, r.hi)}
+			return
+		}
+		c.others[n.String()] = true
+	}
+	split(n)
+	return c
+}
+
+// rangeOf returns the range of an ordered field compared to a constant
+func rangeOf(n exprNode) (string, interval, bool) {
+	b, ok := n.(*binaryNode)
+	if !ok {
+		return "", interval{}, false
+	}
+	field, value, op := b.x, b.y, b.op
+	if _, ok := field.(*identNode); !ok || field.(*identNode).field == nil {
+		// constant op field
+		field, value = b.y, b.x
+		op = map[string]string{">=": "<=", "<=": ">=", ">": "<", "<": ">", "==": "=="}[op]
+	}
+	ident, ok := field.(*identNode)
+	if !ok || ident.field == nil {
+		return "", interval{}, false
+	}
+
+	var v float64
+	switch value := value.(type) {
+	case *literalNode:
+		n, ok := value.value.(float64)
+		if !ok {
+			return "", interval{}, false
+		}
+		v = n
+	case *identNode:
+		if value.level == nil {
+			return "", interval{}, false
+		}
+		switch level := value.level.value.(type) {
+		case report.SeverityLevel:
+			v = float64(level)
+		case report.ConfidenceLevel:
+			v = float64(level)
+		}
+	default:
+		return "", interval{}, false
+	}
+
+	inf := math.Inf(1)
+	switch op {
+	case "==":
+		return ident.name, interval{v, v}, true
+	case ">=":
+		return ident.name, interval{v, inf}, true
+	case ">":
+		return ident.name, interval{math.Nextafter(v, inf), inf}, true
+	case "<=":
+		return ident.name, interval{-inf, v}, true
+	case "<":
+		return ident.name, interval{-inf, math.Nextafter(v, -inf)}, true
+	}
+	return "", interval{}, false
+}
+
+// empty is true when a range cannot be satisfied, or when ordered levels leave no value
+func (c constraints) empty() bool {
+	for _, r := range c.ranges {
+		if r.lo > r.hi || math.Ceil(r.lo) > math.Floor(r.hi) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers is true when every finding matching other matches c
+func (c constraints) covers(other constraints) bool {
+	for text := range c.others {
+		if !other.others[text] {
+			return false
+		}
+	}
+	for field, r := range c.ranges {
+		o, ok := other.ranges[field]
+		if !ok || o.lo < r.lo || o.hi > r.hi {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps is true when c and other share their other conditions and their ranges intersect
+func (c constraints) overlaps(other constraints) bool {
+	if len(c.others) != len(other.others) {
+		return false
+	}
+	for text := range c.others {
+		if !other.others[text] {
+			return false
+		}
+	}
+	for field, r := range c.ranges {
+		if o, ok := other.ranges[field]; ok && math.Max(r.lo, o.lo) > math.Min(r.hi, o.hi) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		covers    bool // covers tells whether a covers b
+		coveredBy bool // coveredBy tells whether b covers a
+		overlaps  bool
+	}{
+		{`severity >= High`, `severity == Critical`, true, false, true},
+		{`severity >= High`, `severity < High`, false, false, false},
+		{`severity >= High`, `High <= severity`, true, true, true},
+		{`severity >= Medium && severity <= High`, `severity >= High`, false, false, true},
+		{`severity >= High`, `severity >= High && category == "sast"`, true, false, false},
+		{`line > 10 && line < 20`, `line == 15`, true, false, true},
+		{`line > 10 && line < 20`, `line >= 20`, false, false, false},
+		{`confidence >= High`, `severity >= High`, false, false, true},
+		{`file.matches("test/**")`, `file.matches("test/**")`, true, true, true},
+		{`file.matches("test/**")`, `file.matches("docs/**")`, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" | "+tt.b, func(t *testing.T) {
+			a, b := constraintsFor(t, tt.a), constraintsFor(t, tt.b)
+			if got := a.covers(b); got != tt.covers {
+				t.Errorf("a covers b: got %v, want %v", got, tt.covers)
+			}
+			if got := b.covers(a); got != tt.coveredBy {
+				t.Errorf("b covers a: got %v, want %v", got, tt.coveredBy)
+			}
+			if got := a.overlaps(b); got != tt.overlaps {
+				t.Errorf("a overlaps b: got %v, want %v", got, tt.overlaps)
+			}
+			if got := b.overlaps(a); got != tt.overlaps {
+				t.Errorf("b overlaps a: got %v, want %v", got, tt.overlaps)
+			}
+		})
+	}
+}
+
+func TestConstraintsEmpty(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`severity == High`, false},
+		{`severity >= High && severity <= High`, false},
+		{`severity >= High && severity <= Medium`, true},
+		{`severity > High && severity < Critical`, true},
+		{`line > 1 && line < 2`, true},
+		{`line > 1 && line < 3`, false},
+		{`line > 10 && dependency.direct`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			if got := constraintsFor(t, tt.src).empty(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func constraintsFor(t *testing.T, src string) constraints {
+	t.Helper()
+	e, err := compileExpr(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return constraintsOf(e.root)
+}
+
+func TestSuppressionCovers(t *testing.T) {
+	suppression := func(s Suppression) *Suppression {
+		s.Justification, s.Approver = "test", "security"
+		if err := s.validate(); err != nil {
+			t.Fatal(err)
+		}
+		return &s
+	}
+
+	tests := []struct {
+		name           string
+		earlier, later Suppression
+		want           bool
+	}{
+		{"same matcher", Suppression{ID: "a", Expires: "2025-01-31"}, Suppression{ID: "a", Expires: "2025-01-31"}, true},
+		{"expires later", Suppression{ID: "a", Expires: "2025-01-31"}, Suppression{ID: "a", Expires: "2024-06-30"}, true},
+		{"expires earlier", Suppression{ID: "a", Expires: "2024-06-30"}, Suppression{ID: "a", Expires: "2025-01-31"}, false},
+		{"narrower later", Suppression{Path: "app/**", Expires: "2025-01-31"}, Suppression{Path: "app/**", Identifier: "G201", Expires: "2025-01-31"}, true},
+		{"wider later", Suppression{Path: "app/**", Identifier: "G201", Expires: "2025-01-31"}, Suppression{Path: "app/**", Expires: "2025-01-31"}, false},
+		{"other identifier", Suppression{Identifier: "G201", Expires: "2025-01-31"}, Suppression{Identifier: "G104", Expires: "2025-01-31"}, false},
+		{"patterns not expanded", Suppression{Path: "app/**", Expires: "2025-01-31"}, Suppression{Path: "app/db/query.go", Expires: "2025-01-31"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suppressionCovers(suppression(tt.earlier), suppression(tt.later)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{
+			name: "rules",
+			file: "rules.yml",
+			content: `rules:
+  - name: high
+    when: severity >= High
+    decision: fail
+  - name: critical sast
+    when: severity == Critical && category == "sast"
+    decision: warn
+  - name: up to high
+    when: severity <= High
+    decision: warn
+  - name: never
+    when: line > 10 && line < 5
+    decision: ignore
+  - name: high
+    when: category == "sast"
+    decision: fail
+    severity: high
+`,
+			want: []string{
+				`rules.yml:5: error: rule "critical sast" is unreachable, rule "high" at line 2 matches every finding it matches`,
+				`rules.yml:8: warning: rule "up to high" overlaps rule "high" at line 2, which decides fail where both match`,
+				`rules.yml:12: error: rule "never" never matches, its conditions contradict each other`,
+				`rules.yml:17: error: rule #5: unknown key "severity", expected one of name, when, decision`,
+				`rules.yml:14: warning: rule name "high" already used at line 2`,
+			},
+		},
+		{
+			name: "suppressions",
+			file: "security-gate.yml",
+			content: `suppressions:
+  - identifier: G201
+    justification: legacy queries
+    approver: security
+    expires: 2024-12-31
+  - identifier: G201
+    path: app/**
+    justification: reviewed
+    approver: security
+    expires: 2024-09-30
+  - identifier: G104
+    justification: old
+    approver: security
+    expires: 2024-01-31
+  - path: test/**
+    justification: fixtures
+    approver: security
+    expires: 2024-12-31
+    owner: qa
+`,
+			want: []string{
+				`security-gate.yml:6: warning: suppression #2 is unreachable, suppression at line 2 matches the same findings until 2024-12-31`,
+				`security-gate.yml:14: error: suppression #3 expired on 2024-01-31`,
+				`security-gate.yml:19: error: suppression #4: unknown key "owner", expected one of id, compare_key, identifier, path, justification, approver, expires`,
+			},
+		},
+		{
+			name:    "configuration",
+			file:    "gate.yml",
+			content: "severity: severe\nwarnings-exit-code: 3\nseverty: high\n",
+			want: []string{
+				`gate.yml:1: error: severity: invalid severity "severe"`,
+				`gate.yml:3: error: unknown key "severty"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tt.file)
+			writeFile(t, path, tt.content)
+
+			fs, _, _ := newConfigFlagSet()
+			l := &linter{flags: fs, now: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), done: make(map[string]bool), extended: make(map[string]bool)}
+			l.lintFile(path)
+
+			var got []string
+			for _, d := range l.diagnostics {
+				rel, _ := filepath.Rel(dir, d.File)
+				d.File = rel
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// Format is the format of a report read by the loader
+type Format string
+
+const (
+	// FormatGitLab is the GitLab security report schema
+	FormatGitLab Format = "gitlab"
+	// FormatSARIF is a SARIF 2.1.0 log
+	FormatSARIF Format = "sarif"
+	// FormatGitleaks is the JSON report of Gitleaks
+	FormatGitleaks Format = "gitleaks"
+	// FormatTruffleHog is the JSON lines output of TruffleHog
+	FormatTruffleHog Format = "trufflehog"
+	// FormatTrivy is the JSON report of Trivy
+	FormatTrivy Format = "trivy"
+	// FormatGrype is the JSON report of Grype
+	FormatGrype Format = "grype"
+)
+
+// Loader reads security reports, converting the native output of other scanners
+// into the GitLab security report schema
+type Loader struct {
+	SARIF    SARIFInputOptions
+	Mappings []*Mapping // Mappings convert the output of in-house scanners, matched on the report file name
+}
+
+// NewLoader returns a loader with the default adapter options
+func NewLoader() *Loader {
+	return &Loader{
+		SARIF: NewSARIFInputOptions(),
+	}
+}
+
+// Load reads a report, detecting its format from its content
+func (l *Loader) Load(path string) (report.Report, error) {
+	var r report.Report
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return r, readError(path, err)
+	}
+
+	for _, m := range l.Mappings {
+		if m.Matches(path) {
+			if r, err = m.Convert(b); err != nil {
+				return r, parseError(path, fmt.Errorf("%s: %s mapping: %w", path, m.Name, err))
+			}
+			return r, nil
+		}
+	}
+
+	format, err := detectFormat(b)
+	if err != nil {
+		return r, parseError(path, fmt.Errorf("%s: %w", path, err))
+	}
+
+	switch format {
+	case FormatSARIF:
+		r, err = l.SARIF.Convert(b)
+	case FormatGitleaks:
+		r, err = convertGitleaks(b)
+	case FormatTruffleHog:
+		r, err = convertTruffleHog(b)
+	case FormatTrivy:
+		r, err = convertTrivy(b)
+	case FormatGrype:
+		r, err = convertGrype(b)
+	default:
+		err = json.Unmarshal(b, &r)
+	}
+	if err != nil {
+		return r, parseError(path, fmt.Errorf("%s: %w", path, err))
+	}
+
+	return r, nil
+}
+
+// Patterns returns the file names searched for in directories, including those of the mappings
+func (l *Loader) Patterns() []string {
+	patterns := append([]string{}, discoveryPatterns...)
+	for _, m := range l.Mappings {
+		patterns = append(patterns, m.Files...)
+	}
+	return patterns
+}
+
+// detectFormat guesses the format of a report from the fields of its first JSON value
+func detectFormat(b []byte) (Format, error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 {
+		return "", errors.New("empty report")
+	}
+
+	// array reports: detect from the fields of the first element
+	if trimmed[0] == '[' {
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return "", err
+		}
+		if len(items) == 0 || hasFields(items[0], "RuleID") {
+			return FormatGitleaks, nil
+		}
+		return "", errors.New("unknown report format")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	var fields map[string]json.RawMessage
+	if err := dec.Decode(&fields); err != nil {
+		return "", err
+	}
+
+	switch {
+	case hasFields(fields, "DetectorName", "SourceMetadata"):
+		return FormatTruffleHog, nil
+	case isSARIF(fields):
+		return FormatSARIF, nil
+	case hasFields(fields, "SchemaVersion") && (hasFields(fields, "ArtifactName") || hasFields(fields, "ArtifactType")):
+		// Trivy leaves Results out of a clean scan
+		return FormatTrivy, nil
+	case hasFields(fields, "matches", "descriptor"):
+		return FormatGrype, nil
+	}
+
+	// a single JSON document is expected from now on
+	if _, err := dec.Token(); err != io.EOF {
+		return "", errors.New("unexpected data after the report")
+	}
+	return FormatGitLab, nil
+}
+
+func hasFields(fields map[string]json.RawMessage, names ...string) bool {
+	for _, name := range names {
+		if _, ok := fields[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
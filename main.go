@@ -1,39 +1,315 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+	"gitlab.com/gitlab-org/security-products/analyzers/ruleset"
 )
 
 func main() {
-	reportFile, err := os.ReadFile("gl-secret-detection-report.json")
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		os.Exit(int(runPolicyTests(os.Args[2:], os.Stdout)))
+	}
+
+	policy := NewPolicy()
+	flag.Var(severityFlag{&policy.Threshold}, "severity", "Minimum severity failing the gate (critical, high, medium, low, unknown, info)")
+	flag.Var(categorySeverityFlag(policy.CategoryThresholds), "category-severity", "Minimum severity failing the gate per category, e.g. container_scanning=critical,secret_detection=medium")
+	flag.Var(confidenceFlag{&policy.Confidence}, "confidence", "Minimum confidence for a finding meeting the severity threshold to fail the gate (confirmed, high, medium, low, experimental, unknown)")
+	flag.Var(confidenceMatrixFlag(policy.ConfidenceMatrix), "confidence-matrix", "Minimum confidence failing the gate per severity, e.g. high=confirmed,critical=low")
+	flag.Var(rulesFlag{&policy.Rules, &policy.RuleFiles}, "rules", "Rule file whose expressions fail, warn or ignore findings ahead of the severity thresholds, can be repeated")
+	suppressionsPath := flag.String("suppressions", "", "Suppression file of accepted findings (default "+defaultSuppressionsPath+" when present)")
+	baselinePath := flag.String("baseline", "", "Previous report, directory or glob (e.g. the default branch artifacts); only findings absent from it fail the gate")
+	diffOnly := flag.Bool("diff-aware", false, "Only fail SAST and secret detection findings on lines changed in the merge request")
+	diffBase := flag.String("diff-base", os.Getenv(EnvVarDiffBaseSHA), "Commit to diff HEAD against with -diff-aware (default $"+EnvVarDiffBaseSHA+")")
+	redactor := Redactor{}
+	flag.IntVar(&redactor.Reveal, "redact-reveal", defaultReveal, "Number of leading characters of a secret left in clear when redacting secret detection findings")
+	noColor := flag.Bool("no-color", os.Getenv("NO_COLOR") != "", "Disable colors in the console output")
+	junitPath := flag.String("junit", "", "Write the gate decisions as a JUnit XML report for artifacts:reports:junit")
+	codeQualityPath := flag.String("codequality", "", "Write failing and warning findings as a GitLab Code Quality report")
+	codeQualitySeverities := make(map[report.SeverityLevel]string)
+	flag.Var(codeQualitySeverityFlag(codeQualitySeverities), "codequality-severity", "Code Quality severity per report severity, e.g. critical=blocker,high=critical,medium=major")
+	sarifPath := flag.String("sarif", "", "Write the evaluated findings as a SARIF 2.1.0 log")
+	loader := NewLoader()
+	sarifCategory := flag.String("sarif-category", string(loader.SARIF.Category), "Category of the findings read from SARIF logs")
+	flag.Var(sarifLevelFlag(loader.SARIF.Levels), "sarif-severity", "Severity of SARIF result levels, e.g. error=high,warning=medium,note=low,none=info; results whose rule has a security-severity property take their severity from it unless -sarif-security-severity=false")
+	flag.BoolVar(&loader.SARIF.SecuritySeverity, "sarif-security-severity", loader.SARIF.SecuritySeverity, "Derive the severity of SARIF results from the security-severity property of their rule (CVSS score: 9 critical, 7 high, 4 medium, above 0 low) ahead of -sarif-severity")
+	flag.Var(mappingFlag{&loader.Mappings}, "mapping", "Mapping file converting the JSON output of another scanner, can be repeated")
+	reportDir := flag.String("report-dir", "", "Write the enforced findings as gl-<category>-report.json files in this directory, for artifacts:reports")
+	reportWarnings := flag.Bool("report-include-warnings", false, "Keep warnings in the reports written with -report-dir")
+	merge := flag.Bool("merge", false, "Merge the reports of each category into one, removing the findings reported by several scanners")
+	mergeDir := flag.String("merge-dir", "", "Write the merged report of each category as gl-<category>-report.json files in this directory, implies -merge")
+	rulesets := NewRulesets()
+	flag.Var(pathFlag{rulesets, report.CategorySast}, "sast-ruleset", "GitLab custom ruleset disabling SAST rules")
+	flag.Var(pathFlag{rulesets, report.CategorySecretDetection}, "secret-detection-ruleset", "GitLab custom ruleset disabling secret detection rules")
+	exclusions := &Exclusions{}
+	flag.Var(excludeFlag{exclusions}, "exclude", "Drop the findings and dependency files under paths matching this doublestar glob, a leading ! includes them again; can be repeated")
+	flag.Var(excludePresetFlag{exclusions}, "exclude-preset", "Comma separated exclusion presets applied before -exclude ("+strings.Join(exclusionPresetNames(), ", ")+")")
+	flag.BoolVar(&rulesets.Force, "ruleset-force", false, "Apply custom rulesets even when "+ruleset.EnvVarGitlabFeatures+" does not enable them, e.g. on self-hosted runners")
+	summaryPath := flag.String("summary", "", "Write the outcome of the gate, its exit code and errors as JSON")
+	warningsExitCode := flag.Int("warnings-exit-code", int(ExitPass), "Exit code when findings only warn, e.g. 3 to tell warnings apart with allow_failure:exit_codes")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [reports]\n       %s test [-v] [-run regexp] dir [flags]\n       %s lint [-policy-dir dir] [files]\n\n", os.Args[0], os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nEvery flag can be set with a %s<FLAG> variable (e.g. %s) or a key of the configuration file.\n", envPrefix, envName("category-severity"))
+		fmt.Fprint(flag.CommandLine.Output(), exitCodesUsage)
+	}
+	configPath := flag.String("config", "", "Configuration file (YAML, TOML or JSON) whose keys are flag names, overridden by "+envPrefix+"* variables and flags; its extends key lists parent configurations which cannot be loosened and whose file paths are relative to them (default "+defaultConfigPaths[0]+" when present)")
+	flag.String("policy-dir", "", "Directory of the policies extended by relative paths not found next to the configuration file, e.g. a mounted organization policy")
+	debug := flag.Bool("debug", false, "Log debug messages, including the effective configuration")
+
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(int(runLint(os.Args[2:], flag.CommandLine, os.Stdout)))
+	}
+
+	// flag errors go through the summary like any other configuration error
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	cfg, err := loadConfig(flag.CommandLine, os.Args[1:], configPath)
+	summary := &Summary{path: *summaryPath}
 	if err != nil {
-		log.Fatal(err)
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(int(ExitPass))
+		}
+		if summary.path == "" {
+			summary.path = flagArg(os.Args[1:], "summary")
+		}
+		if summary.path == "" {
+			summary.path = os.Getenv(envName("summary"))
+		}
+		summary.Fatal(err)
+	}
+	if *debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	cfg.Log(flag.CommandLine)
+	if err := cfg.Enforce(policy); err != nil {
+		summary.Fatal(err)
 	}
 
-	var r report.Report
-	err = json.Unmarshal(reportFile, &r)
+	loader.SARIF.Category = report.Category(*sarifCategory)
+
+	// the default rulesets of a repository extending a policy could loosen it
+	if cfg.Extended() {
+		for _, name := range []string{"sast-ruleset", "secret-detection-ruleset"} {
+			if cfg.Sources[name] == "" {
+				category := flag.Lookup(name).Value.(pathFlag).category
+				ignoreDefault(rulesets.Paths[category])
+				rulesets.Paths[category] = ""
+			}
+		}
+	}
+
+	// the default suppression file of a repository extending a policy could loosen it
+	suppressions := &SuppressionFile{}
+	if *suppressionsPath != "" {
+		suppressions, err = loadSuppressions(*suppressionsPath, true)
+	} else if cfg.Extended() {
+		ignoreDefault(defaultSuppressionsPath)
+	} else {
+		suppressions, err = loadSuppressions(defaultSuppressionsPath, false)
+	}
 	if err != nil {
-		log.Fatal(err)
+		summary.Fatal(configError(*suppressionsPath, err))
 	}
 
-	var result []string
+	inputs := cfg.Reports
 
-	if len(r.Vulnerabilities) > 0 {
-		for _, v := range r.Vulnerabilities {
-			out, err := json.MarshalIndent(v, "", "  ")
+	// the baseline reports are previous findings, not current ones, even when they sit under a report input,
+	// and the outputs of a previous run in the same workspace would be gated twice
+	var skip []string
+	for _, path := range []string{*baselinePath, *reportDir, *mergeDir, *sarifPath, *codeQualityPath, *junitPath, *summaryPath} {
+		if path != "" {
+			skip = append(skip, path)
+		}
+	}
+	files, err := discoverReports(inputs, loader.Patterns(), skip)
+	if err != nil {
+		summary.Fatal(err)
+	}
+	if len(files) == 0 {
+		summary.Fatal(readError("", fmt.Errorf("no security reports found in %v", inputs)))
+	}
+
+	var res Result
+	for _, f := range files {
+		r, err := loader.Load(f)
+		if err != nil {
+			// keep loading the other reports to list every broken input
+			summary.Error(err)
+			continue
+		}
+		n, err := rulesets.Filter(&r, reportCategory(f, r))
+		if err != nil {
+			summary.Fatal(configError(rulesets.Paths[reportCategory(f, r)], err))
+		}
+		if n > 0 {
+			log.Infof("%s: removed %d vulnerabilities disabled by custom ruleset", f, n)
+		}
+		excluded := exclusions.Exclude(&r)
+		if n := excluded.Count(); n > 0 {
+			log.Infof("%s: excluded %d vulnerabilities matching path exclusions", f, n)
+			for _, line := range excluded.Summary(10) {
+				log.Infof("  %s", line)
+			}
+		}
+		if len(excluded.DependencyFiles) > 0 {
+			log.Infof("%s: excluded dependency files %s", f, strings.Join(excluded.DependencyFiles, ", "))
+		}
+		res.Add(f, r)
+	}
+	if len(summary.Errors) > 0 {
+		summary.Exit()
+	}
+
+	if *merge || *mergeDir != "" {
+		merged, duplicates := mergeReports(&res)
+		for _, rr := range merged.Reports {
+			if len(rr.Merged) > 0 {
+				log.Infof("Merged %d %s reports (%s): %d unique vulnerabilities", len(rr.Merged), rr.Category(), strings.Join(rr.Merged, ", "), len(rr.Findings))
+			}
+		}
+		for _, d := range duplicates {
+			log.Infof("Duplicate %s (%s) at %s reported by %s in %s", redactor.Vulnerability(d.Finding.Vulnerability).Name, d.Finding.Category, locationString(d.Finding.Location), strings.Join(d.Scanners, ", "), strings.Join(d.Sources, ", "))
+		}
+		res = *merged
+
+		if *mergeDir != "" {
+			files, err := writeCategoryReports(*mergeDir, mergedReports(&res, redactor))
 			if err != nil {
-				log.Fatal(err)
+				summary.Fatal(err)
+			}
+			for _, f := range files {
+				log.Infof("Wrote merged report %s", f)
+			}
+		}
+	}
+
+	var baseline *Baseline
+	if *baselinePath != "" {
+		if baseline, err = loadBaseline(loader, *baselinePath); err != nil {
+			summary.Fatal(err)
+		}
+	}
+
+	var diff Diff
+	if *diffOnly {
+		if *diffBase == "" {
+			log.Warnf("No diff base set (%s is only available in merge request pipelines), gating every line", EnvVarDiffBaseSHA)
+		} else if diff, err = gitDiff(*diffBase); err != nil {
+			summary.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	for _, f := range res.Findings() {
+		s, expired := suppressions.Match(f, now)
+		for _, e := range expired {
+			log.Warnf("Expired suppression no longer applies to %s in %s: %s", redactor.Vulnerability(f.Vulnerability).Name, f.Location.File, e)
+		}
+		if s != nil {
+			f.Suppression = s
+			f.Decide(DecisionSuppress, s.String())
+			continue
+		}
+		policy.Evaluate(f)
+		if baseline != nil && baseline.Contains(f) {
+			f.Existing = true
+			if f.Decision == DecisionFail {
+				f.Decide(DecisionWarn, "present in baseline")
 			}
-			result = append(result, string(out))
 		}
+		if diff != nil && f.Decision == DecisionFail && diffAware(f.Category) && !diff.Changed(f.Location) {
+			f.Decide(DecisionWarn, "outside the lines changed in the merge request")
+		}
+	}
+
+	for _, rr := range res.Reports {
+		log.Infof("%s (%s): %d vulnerabilities, %d failing (threshold %s)", rr.Path, rr.Category(), len(rr.Findings), len(rr.Filter(DecisionFail)), policy.ThresholdFor(rr.Category()))
 	}
 
-	if len(result) > 0 {
-		log.Fatalf("%d Vulnerabilities detected:\n%s\n", len(result), result)
+	if baseline != nil {
+		var existing, added []*Finding
+		for _, f := range res.Findings() {
+			if f.Existing {
+				existing = append(existing, f)
+			} else {
+				added = append(added, f)
+			}
+		}
+		logBaselineList("New", added, redactor)
+		logBaselineList("Existing", existing, redactor)
+		logBaselineList("Fixed", baseline.Fixed(res.Findings()), redactor)
+	}
+
+	for _, f := range res.Filter(DecisionSuppress) {
+		log.Infof("Suppressed %s (%s) in %s: %s", redactor.Vulnerability(f.Vulnerability).Name, f.Severity, f.Location.File, f.Reason)
+	}
+
+	console := &Console{
+		W:        os.Stdout,
+		Color:    !*noColor,
+		Sections: os.Getenv("GITLAB_CI") == "true",
+		Redactor: redactor,
+	}
+	console.Print(&res)
+
+	if *codeQualityPath != "" {
+		if err := writeCodeQuality(*codeQualityPath, &res, redactor, codeQualitySeverities); err != nil {
+			summary.Fatal(err)
+		}
+	}
+
+	if *sarifPath != "" {
+		if err := writeSARIF(*sarifPath, &res, redactor); err != nil {
+			summary.Fatal(err)
+		}
+	}
+
+	if *reportDir != "" {
+		files, err := writeCategoryReports(*reportDir, filteredReports(&res, redactor, *reportWarnings))
+		if err != nil {
+			summary.Fatal(err)
+		}
+		for _, f := range files {
+			log.Infof("Wrote filtered report %s", f)
+		}
+	}
+
+	if *junitPath != "" {
+		if err := writeJUnit(*junitPath, &res, redactor); err != nil {
+			summary.Fatal(err)
+		}
+	}
+
+	if warnings := res.Filter(DecisionWarn); len(warnings) > 0 {
+		log.Warnf("%d Vulnerabilities reported as warnings", len(warnings))
+	}
+
+	if failures := res.Filter(DecisionFail); len(failures) > 0 {
+		log.Errorf("%d Vulnerabilities detected across %d reports", len(failures), len(res.Reports))
+	}
+
+	summary.Result(&res, ExitCode(*warningsExitCode), redactor)
+	summary.Exit()
+}
+
+// ignoreDefault warns about a policy file of the repository left out because the configuration extends another policy
+func ignoreDefault(path string) {
+	if _, err := os.Stat(path); err == nil {
+		log.Warnf("Ignoring %s: the configuration extends another policy, which the files of the repository cannot loosen", path)
+	}
+}
+
+func logBaselineList(status string, findings []*Finding, redactor Redactor) {
+	log.Infof("%s vulnerabilities relative to baseline: %d", status, len(findings))
+	for _, f := range findings {
+		log.Infof("  [%s] %s (%s) in %s", f.Category, redactor.Vulnerability(f.Vulnerability).Name, f.Severity, f.Location.File)
 	}
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	//  Used for location fingerprinting, not cryptographically secure
+	"crypto/sha1" // #nosec
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// Duplicate is a finding reported more than once across the merged reports of a category
+type Duplicate struct {
+	Finding  *Finding // Finding is the occurrence kept in the merged report
+	Scanners []string // Scanners lists every scanner that reported the finding
+	Sources  []string // Sources lists every report the finding was read from
+}
+
+// mergeReports combines the reports of each category with report.MergeReports,
+// which dedupes and sorts the vulnerabilities. Categories with a single report are left as is.
+// The findings removed as duplicates are returned with the scanners that reported them.
+func mergeReports(res *Result) (*Result, []Duplicate) {
+	byCategory := make(map[report.Category][]*ReportResult)
+	var categories []report.Category
+	for _, rr := range res.Reports {
+		category := rr.Category()
+		if _, ok := byCategory[category]; !ok {
+			categories = append(categories, category)
+		}
+		byCategory[category] = append(byCategory[category], rr)
+	}
+
+	merged := &Result{}
+	var duplicates []Duplicate
+	for _, category := range categories {
+		rrs := byCategory[category]
+		if len(rrs) == 1 {
+			merged.Reports = append(merged.Reports, rrs[0])
+			continue
+		}
+
+		var paths []string
+		var reports []report.Report
+		var findings []*Finding
+		for _, rr := range rrs {
+			paths = append(paths, rr.Path)
+			r := rr.Report
+			r.Vulnerabilities = nil
+			for _, f := range rr.Findings {
+				r.Vulnerabilities = append(r.Vulnerabilities, f.Vulnerability)
+				findings = append(findings, f)
+			}
+			reports = append(reports, r)
+		}
+
+		r := report.MergeReports(reports...)
+		r.Scan = rrs[0].Report.Scan
+		r.Scan.Type = category
+		r.Scan.Scanner.Name = strings.Join(scannerNames(rrs), ", ")
+
+		// MergeReports drops the origin of the vulnerabilities, restore it from the source findings
+		sources := make(map[string]string)
+		for _, f := range findings {
+			if _, ok := sources[f.ID()]; !ok {
+				sources[f.ID()] = f.Source
+			}
+		}
+		rr := merged.Add(strings.Join(paths, ","), r)
+		rr.Merged = paths
+		for _, f := range rr.Findings {
+			if source, ok := sources[f.ID()]; ok {
+				f.Source = source
+			}
+		}
+
+		duplicates = append(duplicates, findDuplicates(findings)...)
+	}
+
+	return merged, duplicates
+}
+
+// scannerNames lists the scanners of the merged reports, in order
+func scannerNames(rrs []*ReportResult) []string {
+	var names []string
+	for _, rr := range rrs {
+		if name := rr.Report.Scan.Scanner.Name; name != "" {
+			names = appendUnique(names, name)
+		}
+	}
+	return names
+}
+
+// findDuplicates groups findings sharing a location and an identifier other than a CWE,
+// the rule report.Dedupe applies. The first finding of each group is the one kept.
+func findDuplicates(findings []*Finding) []Duplicate {
+	type group struct {
+		first    *Finding
+		scanners []string
+		sources  []string
+		count    int
+	}
+
+	var groups []*group
+	seen := make(map[string]*group)
+	for _, f := range findings {
+		keys := dedupeKeys(f.Vulnerability)
+
+		var g *group
+		for _, key := range keys {
+			if g = seen[key]; g != nil {
+				break
+			}
+		}
+		if g == nil {
+			g = &group{first: f}
+			groups = append(groups, g)
+			for _, key := range keys {
+				seen[key] = g
+			}
+		}
+
+		g.count++
+		g.scanners = appendUnique(g.scanners, f.Scanner.Name)
+		g.sources = appendUnique(g.sources, f.Source)
+	}
+
+	var duplicates []Duplicate
+	for _, g := range groups {
+		if g.count > 1 {
+			sort.Strings(g.scanners)
+			duplicates = append(duplicates, Duplicate{Finding: g.first, Scanners: g.scanners, Sources: g.sources})
+		}
+	}
+	return duplicates
+}
+
+// dedupeKeys returns the keys report.Dedupe compares vulnerabilities on
+func dedupeKeys(v report.Vulnerability) []string {
+	b, err := json.Marshal(v.Location)
+	if err != nil {
+		return nil
+	}
+	locSHA1 := fmt.Sprintf("%x", sha1.Sum(b)) // #nosec
+
+	var keys []string
+	for _, id := range v.Identifiers {
+		if id.Type != report.IdentifierTypeCWE {
+			keys = append(keys, strings.Join([]string{locSHA1, string(id.Type), id.Value}, "|"))
+		}
+	}
+	return keys
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
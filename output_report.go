@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// filteredReports builds one GitLab report per category holding the findings the gate enforced.
+// Suppressed findings are dropped, as are warnings unless includeWarnings is set.
+// Each report carries the scan metadata of the first source report of its category.
+func filteredReports(res *Result, redactor Redactor, includeWarnings bool) map[report.Category]report.Report {
+	reports := make(map[report.Category]report.Report)
+
+	for _, rr := range res.Reports {
+		category := rr.Category()
+		out, ok := reports[category]
+		if !ok {
+			out = report.NewReport()
+			out.Scan = rr.Report.Scan
+			out.Scan.Type = category
+		}
+
+		kept := make(map[string]bool)
+		for _, f := range rr.Findings {
+			if f.Decision == DecisionFail || (includeWarnings && f.Decision == DecisionWarn) {
+				out.Vulnerabilities = append(out.Vulnerabilities, redactor.Vulnerability(f.Vulnerability))
+				kept[f.CompareKey] = true
+			}
+		}
+
+		for _, rem := range rr.Report.Remediations {
+			for _, ref := range rem.Fixes {
+				if kept[ref.CompareKey] {
+					out.Remediations = append(out.Remediations, rem)
+					break
+				}
+			}
+		}
+		out.DependencyFiles = append(out.DependencyFiles, rr.Report.DependencyFiles...)
+
+		reports[category] = out
+	}
+
+	for category, r := range reports {
+		r.Dedupe()
+		r.Sort()
+		reports[category] = r
+	}
+	return reports
+}
+
+// mergedReports returns the report of each category of a merged result, deduped and sorted,
+// with every finding whatever the gate decided
+func mergedReports(res *Result, redactor Redactor) map[report.Category]report.Report {
+	reports := make(map[report.Category]report.Report)
+	for _, rr := range res.Reports {
+		r := rr.Report
+		r.Vulnerabilities = make([]report.Vulnerability, 0, len(rr.Findings))
+		for _, f := range rr.Findings {
+			r.Vulnerabilities = append(r.Vulnerabilities, redactor.Vulnerability(f.Vulnerability))
+		}
+		r.Dedupe()
+		r.Sort()
+		reports[rr.Category()] = r
+	}
+	return reports
+}
+
+// writeCategoryReports writes reports as gl-<category>-report.json files in dir
+func writeCategoryReports(dir string, reports map[report.Category]report.Report) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var categories []string
+	for category := range reports {
+		categories = append(categories, string(category))
+	}
+	sort.Strings(categories)
+
+	var files []string
+	for _, category := range categories {
+		r := reports[report.Category(category)]
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		filename := filepath.Join(dir, fmt.Sprintf("gl-%s-report.json", strings.ReplaceAll(category, "_", "-")))
+		if err := os.WriteFile(filename, append(b, '\n'), 0644); err != nil {
+			return nil, err
+		}
+		files = append(files, filename)
+	}
+	return files, nil
+}
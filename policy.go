@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// Policy decides whether a finding fails the gate
+type Policy struct {
+	Threshold          report.SeverityLevel                     // Threshold is the minimum severity that fails the gate
+	CategoryThresholds map[report.Category]report.SeverityLevel // CategoryThresholds overrides Threshold per report category
+
+	// Confidence is the minimum confidence for a finding meeting the severity threshold to fail the gate
+	Confidence report.ConfidenceLevel
+	// ConfidenceMatrix overrides Confidence per severity level
+	ConfidenceMatrix map[report.SeverityLevel]report.ConfidenceLevel
+
+	// Rules decide ahead of the thresholds, the first matching rule wins
+	Rules     []*Rule
+	RuleFiles []string
+
+	// Floor holds the thresholds of the extended policies: only their own rules may warn on
+	// or ignore the findings these thresholds fail
+	Floor thresholds
+}
+
+// NewPolicy returns a policy failing on every finding, whatever its severity
+func NewPolicy() *Policy {
+	return &Policy{
+		Threshold:          report.SeverityLevelInfo,
+		CategoryThresholds: make(map[report.Category]report.SeverityLevel),
+		Confidence:         report.ConfidenceLevelUndefined,
+		ConfidenceMatrix:   make(map[report.SeverityLevel]report.ConfidenceLevel),
+	}
+}
+
+// ThresholdFor returns the minimum severity failing the gate for the given category
+func (p *Policy) ThresholdFor(category report.Category) report.SeverityLevel {
+	if t, ok := p.CategoryThresholds[category]; ok {
+		return t
+	}
+	return p.Threshold
+}
+
+// ConfidenceFor returns the minimum confidence failing the gate for the given severity
+func (p *Policy) ConfidenceFor(severity report.SeverityLevel) report.ConfidenceLevel {
+	if c, ok := p.ConfidenceMatrix[severity]; ok {
+		return c
+	}
+	return p.Confidence
+}
+
+// Evaluate records the policy decision on the finding
+func (p *Policy) Evaluate(f *Finding) {
+	for _, r := range p.Rules {
+		if r.Matches(f) {
+			if limit, ok := p.floorFails(f); ok && r.decision != DecisionFail && !r.extended {
+				f.Decide(DecisionFail, fmt.Sprintf("rule %q cannot %s a finding meeting the %s threshold of the extended policy", r.Name, r.decision, limit))
+				return
+			}
+			f.Decide(r.decision, fmt.Sprintf("rule %q", r.Name))
+			return
+		}
+	}
+
+	severity := severityOf(f.Vulnerability)
+	threshold := p.ThresholdFor(f.Category)
+	if severity < threshold {
+		f.Decide(DecisionWarn, fmt.Sprintf("severity %s below %s threshold", severity, threshold))
+		return
+	}
+
+	confidence := confidenceOf(f.Vulnerability)
+	if min := p.ConfidenceFor(severity); confidence < min {
+		f.Decide(DecisionWarn, fmt.Sprintf("confidence %s below %s required for %s severity", confidence, min, severity))
+		return
+	}
+
+	f.Decide(DecisionFail, fmt.Sprintf("severity %s meets %s threshold", severity, threshold))
+}
+
+// floorFails returns the threshold of the extended policies failing the finding, if any
+func (p *Policy) floorFails(f *Finding) (report.SeverityLevel, bool) {
+	limit, ok := p.Floor.limit(f.Category)
+	if !ok {
+		return limit, false
+	}
+	severity := severityOf(f.Vulnerability)
+	return limit, severity >= limit && confidenceOf(f.Vulnerability) >= p.ConfidenceFor(severity)
+}
+
+// severityOf returns the severity of a vulnerability, treating an undefined severity as unknown
+func severityOf(v report.Vulnerability) report.SeverityLevel {
+	if v.Severity == report.SeverityLevelUndefined {
+		return report.SeverityLevelUnknown
+	}
+	return v.Severity
+}
+
+// confidenceOf returns the confidence of a vulnerability, treating an undefined confidence as unknown
+func confidenceOf(v report.Vulnerability) report.ConfidenceLevel {
+	if v.Confidence == report.ConfidenceLevelUndefined {
+		return report.ConfidenceLevelUnknown
+	}
+	return v.Confidence
+}
+
+// parseSeverity parses a severity level, rejecting values report.ParseSeverityLevel would map to Unknown
+func parseSeverity(s string) (report.SeverityLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "critical", "high", "medium", "low", "unknown", "experimental", "info", "ignore":
+		return report.ParseSeverityLevel(s), nil
+	}
+	return report.SeverityLevelUndefined, fmt.Errorf("invalid severity %q", s)
+}
+
+// parseConfidence parses a confidence level, rejecting values report.ParseConfidenceLevel would map to Unknown
+func parseConfidence(s string) (report.ConfidenceLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "confirmed", "critical", "high", "medium", "low", "experimental", "unknown", "ignore":
+		return report.ParseConfidenceLevel(s), nil
+	}
+	return report.ConfidenceLevelUndefined, fmt.Errorf("invalid confidence %q", s)
+}
+
+// severityFlag is a flag.Value holding a severity level
+type severityFlag struct {
+	level *report.SeverityLevel
+}
+
+func (f severityFlag) String() string {
+	if f.level == nil {
+		return ""
+	}
+	return f.level.String()
+}
+
+func (f severityFlag) Set(s string) error {
+	level, err := parseSeverity(s)
+	if err != nil {
+		return err
+	}
+	*f.level = level
+	return nil
+}
+
+// categorySeverityFlag is a flag.Value holding severity levels per category,
+// given as a comma separated list of category=severity pairs
+type categorySeverityFlag map[report.Category]report.SeverityLevel
+
+func (f categorySeverityFlag) String() string {
+	var pairs []string
+	for category, level := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", category, level))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f categorySeverityFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid category threshold %q, expected category=severity", pair)
+		}
+		level, err := parseSeverity(parts[1])
+		if err != nil {
+			return err
+		}
+		f[report.Category(strings.TrimSpace(parts[0]))] = level
+	}
+	return nil
+}
+
+// confidenceFlag is a flag.Value holding a confidence level
+type confidenceFlag struct {
+	level *report.ConfidenceLevel
+}
+
+func (f confidenceFlag) String() string {
+	if f.level == nil {
+		return ""
+	}
+	return f.level.String()
+}
+
+func (f confidenceFlag) Set(s string) error {
+	level, err := parseConfidence(s)
+	if err != nil {
+		return err
+	}
+	*f.level = level
+	return nil
+}
+
+// confidenceMatrixFlag is a flag.Value holding the minimum confidence per severity,
+// given as a comma separated list of severity=confidence pairs
+type confidenceMatrixFlag map[report.SeverityLevel]report.ConfidenceLevel
+
+func (f confidenceMatrixFlag) String() string {
+	var pairs []string
+	for severity, level := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", severity, level))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f confidenceMatrixFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid confidence matrix entry %q, expected severity=confidence", pair)
+		}
+		severity, err := parseSeverity(parts[0])
+		if err != nil {
+			return err
+		}
+		level, err := parseConfidence(parts[1])
+		if err != nil {
+			return err
+		}
+		f[severity] = level
+	}
+	return nil
+}
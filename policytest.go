@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// expectedFile is the sidecar file of a policy test case, next to its fixture reports
+const expectedFile = "expected.yml"
+
+// Expected are the verdicts a policy test case asserts. Findings are referred to by ID or compare key,
+// and an omitted assertion is not checked.
+type Expected struct {
+	Fail     *[]string `yaml:"fail"`      // Fail lists every finding expected to fail the gate
+	Warn     *[]string `yaml:"warn"`      // Warn lists every finding expected to warn
+	ExitCode *int      `yaml:"exit_code"` // ExitCode is the expected exit code of the gate
+	Args     []string  `yaml:"args"`      // Args are additional gate flags for this case
+}
+
+// PolicyTest is a directory of fixture reports along with their expected verdicts
+type PolicyTest struct {
+	Name     string
+	Dir      string
+	Expected Expected
+}
+
+// runPolicyTests implements the test subcommand: it runs the gate on every test case of a directory
+// and compares the outcome with the expected verdicts. Flags following the directory are passed to the gate.
+func runPolicyTests(args []string, stdout io.Writer) ExitCode {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := fs.Bool("v", false, "Print the name of every case and the gate output of failing cases")
+	run := fs.String("run", "", "Only run the cases whose name matches this regular expression")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s test [-v] [-run regexp] dir [gate flags]\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Runs the gate on each subdirectory of dir holding an %s sidecar file and the fixture reports.\n", expectedFile)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return ExitConfig
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return ExitConfig
+	}
+	dir, gateArgs := fs.Arg(0), fs.Args()[1:]
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Fprintf(stdout, "invalid -run: %s\n", err)
+			return ExitConfig
+		}
+	}
+
+	tests, err := loadPolicyTests(dir)
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return ExitConfig
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(stdout, err)
+		return ExitError
+	}
+
+	start := time.Now()
+	failed, ran := 0, 0
+	for _, t := range tests {
+		if filter != nil && !filter.MatchString(t.Name) {
+			continue
+		}
+		ran++
+		if *verbose {
+			fmt.Fprintf(stdout, "=== RUN   %s\n", t.Name)
+		}
+
+		caseStart := time.Now()
+		problems, output, err := t.Run(executable, gateArgs)
+		elapsed := time.Since(caseStart).Seconds()
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		if len(problems) == 0 {
+			if *verbose {
+				fmt.Fprintf(stdout, "--- PASS: %s (%.2fs)\n", t.Name, elapsed)
+			}
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(stdout, "--- FAIL: %s (%.2fs)\n", t.Name, elapsed)
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "    %s\n", p)
+		}
+		if *verbose {
+			for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+				fmt.Fprintf(stdout, "        %s\n", line)
+			}
+		}
+	}
+
+	elapsed := time.Since(start).Seconds()
+	if failed > 0 {
+		fmt.Fprintf(stdout, "FAIL\t%s\t%.3fs\t%d of %d cases failed\n", dir, elapsed, failed, ran)
+		return ExitFindings
+	}
+	fmt.Fprintf(stdout, "ok  \t%s\t%.3fs\t%d cases\n", dir, elapsed, ran)
+	return ExitPass
+}
+
+// loadPolicyTests returns the test cases of a directory, sorted by name: its subdirectories holding an expected file
+// or the directory itself. A directory holding cases cannot be a case, since the gate would discover
+// the fixture reports of every case in it.
+func loadPolicyTests(dir string) ([]*PolicyTest, error) {
+	var candidates []string
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			candidates = append(candidates, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	var tests []*PolicyTest
+	for _, candidate := range candidates {
+		t, err := loadPolicyTest(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			tests = append(tests, t)
+		}
+	}
+	root, err := loadPolicyTest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if root != nil {
+		if len(tests) > 0 {
+			return nil, fmt.Errorf("%s: a directory holding test cases cannot be a case itself", filepath.Join(dir, expectedFile))
+		}
+		tests = append(tests, root)
+	}
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("no test cases in %s: no %s file found", dir, expectedFile)
+	}
+	sort.Slice(tests, func(i, j int) bool { return tests[i].Dir < tests[j].Dir })
+	return tests, nil
+}
+
+// loadPolicyTest reads the expected file of a test case directory, returning nil when there is none
+func loadPolicyTest(dir string) (*PolicyTest, error) {
+	path := filepath.Join(dir, expectedFile)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a misspelled assertion would otherwise never run and the case would pass
+	t := &PolicyTest{Name: filepath.Base(dir), Dir: dir}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&t.Expected); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Run executes the gate on the fixture reports and returns the unmet expectations along with the gate output
+func (t *PolicyTest) Run(executable string, gateArgs []string) ([]string, string, error) {
+	tmp, err := os.CreateTemp("", "security-gate-summary-*.json")
+	if err != nil {
+		return nil, "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	args := append(append([]string{}, gateArgs...), t.Expected.Args...)
+	args = append(args, "-no-color", "-summary", tmp.Name(), t.Dir)
+
+	var output bytes.Buffer
+	cmd := exec.Command(executable, args...) // #nosec G204
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = append(os.Environ(), "GITLAB_CI=false")
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, output.String(), err
+		}
+	}
+	exitCode := ExitCode(cmd.ProcessState.ExitCode())
+
+	var summary Summary
+	b, err := os.ReadFile(tmp.Name())
+	if err == nil {
+		err = json.Unmarshal(b, &summary)
+	}
+	if err != nil {
+		return nil, output.String(), fmt.Errorf("reading gate summary: %w (exit code %d)", err, exitCode)
+	}
+
+	var problems []string
+	for _, e := range summary.Errors {
+		problems = append(problems, fmt.Sprintf("gate error: %s", e.Message))
+	}
+	if t.Expected.ExitCode != nil && ExitCode(*t.Expected.ExitCode) != exitCode {
+		problems = append(problems, fmt.Sprintf("exit code %d (%s), expected %d (%s)", exitCode, exitCode, *t.Expected.ExitCode, ExitCode(*t.Expected.ExitCode)))
+	}
+	if t.Expected.Fail != nil {
+		problems = append(problems, compareVerdicts(DecisionFail, *t.Expected.Fail, summary.Findings)...)
+	}
+	if t.Expected.Warn != nil {
+		problems = append(problems, compareVerdicts(DecisionWarn, *t.Expected.Warn, summary.Findings)...)
+	}
+	return problems, output.String(), nil
+}
+
+// compareVerdicts lists the expected findings missing from the given decision and the unexpected ones
+func compareVerdicts(d Decision, expected []string, findings []SummaryFinding) []string {
+	var problems []string
+	matched := make(map[string]bool)
+	for _, ref := range expected {
+		found := false
+		for _, f := range findings {
+			if f.ID == ref || f.CompareKey == ref {
+				found = true
+				if f.Decision != d.String() {
+					problems = append(problems, fmt.Sprintf("%s: decision %s (%s), expected %s", ref, f.Decision, f.Reason, d))
+				}
+				matched[f.ID] = true
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: expected to %s, not found among failing and warning findings", ref, d))
+		}
+	}
+	for _, f := range findings {
+		if f.Decision == d.String() && !matched[f.ID] {
+			problems = append(problems, fmt.Sprintf("%s: unexpected %s of %q (%s)", f.ID, d, f.Name, f.Reason))
+		}
+	}
+	return problems
+}
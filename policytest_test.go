@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// envRunGate makes the test binary run the gate, standing in for the executable re-run by the test subcommand
+const envRunGate = "SECURITY_GATE_TEST_RUN_GATE"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envRunGate) != "" {
+		main()
+	}
+	os.Exit(m.Run())
+}
+
+func TestLoadPolicyTests(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "subdirectories",
+			files: map[string]string{
+				"b/expected.yml":        "fail: []",
+				"a/expected.yml":        "warn: [x]",
+				"fixtures/gl-sast.json": "{}",
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name:  "directory itself",
+			files: map[string]string{"expected.yml": "exit_code: 0", "reports/gl-sast-report.json": "{}"},
+			want:  []string{"."},
+		},
+		{
+			name:    "directory holding cases",
+			files:   map[string]string{"expected.yml": "exit_code: 0", "a/expected.yml": "exit_code: 0"},
+			wantErr: "a directory holding test cases cannot be a case itself",
+		},
+		{
+			name:    "unknown assertion",
+			files:   map[string]string{"a/expected.yml": "fial: [x]"},
+			wantErr: "field fial not found",
+		},
+		{
+			name:    "no cases",
+			files:   map[string]string{"a/gl-sast-report.json": "{}"},
+			wantErr: "no test cases",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeFile(t, filepath.Join(dir, name), content)
+			}
+
+			cases, err := loadPolicyTests(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range cases {
+				rel, _ := filepath.Rel(dir, c.Dir)
+				got = append(got, rel)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareVerdicts(t *testing.T) {
+	findings := []SummaryFinding{
+		{ID: "1", CompareKey: "k1", Name: "A", Decision: "fail"},
+		{ID: "2", CompareKey: "k2", Name: "B", Decision: "warn"},
+		{ID: "3", CompareKey: "k3", Name: "C", Decision: "fail"},
+	}
+
+	tests := []struct {
+		name     string
+		decision Decision
+		expected []string
+		want     []string
+	}{
+		{"by ID", DecisionFail, []string{"1", "3"}, nil},
+		{"by compare key", DecisionFail, []string{"k1", "k3"}, nil},
+		{"missing", DecisionWarn, []string{"2", "4"}, []string{"4: expected to warn, not found among failing and warning findings"}},
+		{"unexpected", DecisionFail, []string{"1"}, []string{`3: unexpected fail of "C" ()`}},
+		{"other decision", DecisionWarn, []string{"2", "1"}, []string{"1: decision fail (), expected warn"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareVerdicts(tt.decision, tt.expected, findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPolicyTests(t *testing.T) {
+	t.Setenv(envRunGate, "1")
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "pass", "gl-secret-detection-report.json"), secretReport("a.env", "b.env"))
+	writeFile(t, filepath.Join(dir, "pass", expectedFile), "fail: [a.env, b.env]\nexit_code: 1\n")
+	writeFile(t, filepath.Join(dir, "fail", "gl-secret-detection-report.json"), secretReport("a.env"))
+	writeFile(t, filepath.Join(dir, "fail", expectedFile), "warn: [a.env]\nexit_code: 0\nargs: [-severity, critical]\n")
+
+	var out bytes.Buffer
+	if code := runPolicyTests([]string{dir}, &out); code != ExitFindings {
+		t.Errorf("exit code %d, want %d", code, ExitFindings)
+	}
+	for _, want := range []string{
+		"--- FAIL: fail",
+		"a.env: decision fail (severity Critical meets Critical threshold), expected warn",
+		"exit code 1 (findings), expected 0 (pass)",
+		"1 of 2 cases failed",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "--- FAIL: pass") {
+		t.Errorf("pass case failed:\n%s", out.String())
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+// defaultReveal is the default number of leading characters of a secret left in clear
+const defaultReveal = 4
+
+// Redactor masks the secrets exposed by secret detection findings before they are printed
+type Redactor struct {
+	Reveal int // Reveal is the number of leading characters of a secret left in clear
+}
+
+// Mask hides a secret, keeping the first characters and a short hash for correlation.
+// At most a quarter of the characters of the secret is ever revealed.
+func (r Redactor) Mask(secret string) string {
+	runes := []rune(secret)
+	reveal := r.Reveal
+	if max := len(runes) / 4; reveal > max {
+		reveal = max
+	}
+	if reveal < 0 {
+		reveal = 0
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return fmt.Sprintf("%s****[sha256:%x]", string(runes[:reveal]), sum[:6])
+}
+
+// Vulnerability returns a copy of the vulnerability with its secret masked in every text field.
+// Only secret detection findings are redacted.
+func (r Redactor) Vulnerability(v report.Vulnerability) report.Vulnerability {
+	if v.Category != report.CategorySecretDetection {
+		return v
+	}
+
+	secret := strings.TrimSpace(v.RawSourceCodeExtract)
+	if secret == "" {
+		return v
+	}
+	mask := r.Mask(secret)
+
+	replace := func(s string) string {
+		return strings.ReplaceAll(s, secret, mask)
+	}
+	v.RawSourceCodeExtract = mask
+	v.Name = replace(v.Name)
+	v.Message = replace(v.Message)
+	v.Description = replace(v.Description)
+	v.Solution = replace(v.Solution)
+	v.CompareKey = replace(v.CompareKey)
+
+	if v.Location.Commit != nil {
+		commit := *v.Location.Commit
+		commit.Message = replace(commit.Message)
+		v.Location.Commit = &commit
+	}
+
+	return v
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule decides the outcome of the findings matching its expression, ahead of the severity thresholds
+type Rule struct {
+	Name     string `yaml:"name"`     // Name of the rule, shown as the reason of its decisions
+	When     string `yaml:"when"`     // When is the expression a finding must match
+	Decision string `yaml:"decision"` // Decision is fail, warn or ignore
+
+	expr     *Expr
+	decision Decision
+	file     string // file is the rule file the rule was loaded from
+	extended bool   // extended is set for the rules of an extended policy, which may go below its thresholds
+}
+
+// RuleFile is a file of rules, the first matching rule deciding
+type RuleFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// loadRules reads and compiles a rule file
+func loadRules(filename string) ([]*Rule, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var f RuleFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	for i, r := range f.Rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule #%d: %w", filename, i+1, err)
+		}
+		r.file = filename
+	}
+	return f.Rules, nil
+}
+
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.When == "" {
+		return errors.New("when is required")
+	}
+
+	switch strings.ToLower(r.Decision) {
+	case "fail":
+		r.decision = DecisionFail
+	case "warn":
+		r.decision = DecisionWarn
+	case "ignore":
+		r.decision = DecisionIgnore
+	default:
+		return fmt.Errorf("invalid decision %q, expected fail, warn or ignore", r.Decision)
+	}
+
+	var err error
+	if r.expr, err = compileExpr(r.When); err != nil {
+		return fmt.Errorf("%s: %w", r.Name, err)
+	}
+	return nil
+}
+
+// Matches is true when the finding matches the rule expression
+func (r *Rule) Matches(f *Finding) bool {
+	return r.expr.Match(f)
+}
+
+// rulesFlag is a flag.Value loading a rule file, it can be repeated
+type rulesFlag struct {
+	rules *[]*Rule
+	paths *[]string
+}
+
+func (f rulesFlag) String() string {
+	if f.paths == nil {
+		return ""
+	}
+	return strings.Join(*f.paths, ",")
+}
+
+func (f rulesFlag) Set(s string) error {
+	rules, err := loadRules(s)
+	if err != nil {
+		return err
+	}
+	*f.rules = append(*f.rules, rules...)
+	*f.paths = append(*f.paths, s)
+	return nil
+}
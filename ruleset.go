@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+	"gitlab.com/gitlab-org/security-products/analyzers/ruleset"
+)
+
+// secretsAnalyzer is the name of the secret detection analyzer in custom ruleset files
+const secretsAnalyzer = "secrets"
+
+// Rulesets locates the GitLab custom ruleset files disabling rules per category
+type Rulesets struct {
+	Paths map[report.Category]string // Paths are the ruleset files per category
+	Force bool                       // Force enables custom rulesets even when GITLAB_FEATURES does not list them
+
+	explicit map[report.Category]bool // explicit are the categories whose ruleset file was given rather than defaulted
+}
+
+// NewRulesets returns the default ruleset locations used by the GitLab analyzers
+func NewRulesets() *Rulesets {
+	return &Rulesets{
+		Paths: map[report.Category]string{
+			report.CategorySast:            ruleset.PathSAST,
+			report.CategorySecretDetection: ruleset.PathSecretDetection,
+		},
+		explicit: make(map[report.Category]bool),
+	}
+}
+
+// enable sets the GitLab feature checked by the ruleset package before loading custom rulesets
+func (rs *Rulesets) enable() {
+	features := os.Getenv(ruleset.EnvVarGitlabFeatures)
+	if strings.Contains(features, ruleset.GitlabFeatureCustomRulesetsSAST) {
+		return
+	}
+	if features != "" {
+		features += ","
+	}
+	os.Setenv(ruleset.EnvVarGitlabFeatures, features+ruleset.GitlabFeatureCustomRulesetsSAST)
+}
+
+// Filter removes the vulnerabilities whose identifiers are disabled in the ruleset of the report's analyzer.
+// It returns the number of vulnerabilities removed. A missing ruleset file is only an error when it was given.
+func (rs *Rulesets) Filter(r *report.Report, category report.Category) (int, error) {
+	path, ok := rs.Paths[category]
+	if !ok || path == "" {
+		return 0, nil
+	}
+	if rs.explicit[category] {
+		if _, err := os.Stat(path); err != nil {
+			return 0, fmt.Errorf("%s ruleset: %w", category, err)
+		}
+	}
+	if rs.Force {
+		rs.enable()
+	}
+
+	analyzer := r.Scan.Scanner.ID
+	if category == report.CategorySecretDetection {
+		analyzer = secretsAnalyzer
+	}
+
+	disabled, err := ruleset.DisabledIdentifiers(path, analyzer)
+	if err != nil {
+		switch err.(type) {
+		case *ruleset.NotEnabledError, *ruleset.ConfigFileNotFoundError, *ruleset.ConfigNotFoundError:
+			log.Debug(err)
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(disabled) == 0 {
+		return 0, nil
+	}
+
+	before := len(r.Vulnerabilities)
+	r.FilterDisabledRules(path, analyzer)
+	return before - len(r.Vulnerabilities), nil
+}
+
+// pathFlag is a flag.Value setting the ruleset file of a category
+type pathFlag struct {
+	rulesets *Rulesets
+	category report.Category
+}
+
+func (f pathFlag) String() string {
+	if f.rulesets == nil {
+		return ""
+	}
+	return f.rulesets.Paths[f.category]
+}
+
+func (f pathFlag) Set(s string) error {
+	f.rulesets.Paths[f.category] = s
+	f.rulesets.explicit[f.category] = true
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+	"gitlab.com/gitlab-org/security-products/analyzers/ruleset"
+)
+
+func TestRulesetsFilter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sast-ruleset.toml")
+	writeFile(t, path, "[semgrep]\n  [[semgrep.ruleset]]\n    disable = true\n    [semgrep.ruleset.identifier]\n      type = \"semgrep_id\"\n      value = \"gosec.G104-1\"\n")
+
+	tests := []struct {
+		name    string
+		path    string // path is given with -sast-ruleset unless empty
+		force   bool
+		want    int
+		wantErr string
+	}{
+		{"default missing", "", true, 0, ""},
+		{"given", path, true, 1, ""},
+		{"given not enabled", path, false, 0, ""},
+		{"given missing", filepath.Join(dir, "missing.toml"), true, 0, "no such file or directory"},
+		{"given missing not enabled", filepath.Join(dir, "missing.toml"), false, 0, "no such file or directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ruleset.EnvVarGitlabFeatures, "")
+			rs := NewRulesets()
+			rs.Force = tt.force
+			rs.Paths[report.CategorySast] = filepath.Join(dir, ruleset.PathSAST)
+			if tt.path != "" {
+				if err := (pathFlag{rs, report.CategorySast}).Set(tt.path); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := report.NewReport()
+			r.Scan.Scanner.ID = "semgrep"
+			for _, id := range []string{"gosec.G104-1", "gosec.G201-1"} {
+				r.Vulnerabilities = append(r.Vulnerabilities, report.Vulnerability{
+					Category:    report.CategorySast,
+					Identifiers: []report.Identifier{{Type: "semgrep_id", Name: id, Value: id}},
+				})
+			}
+
+			got, err := rs.Filter(&r, report.CategorySast)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || len(r.Vulnerabilities) != 2-tt.want {
+				t.Errorf("removed %d, %d left, want %d removed", got, len(r.Vulnerabilities), tt.want)
+			}
+		})
+	}
+}
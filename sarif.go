@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SARIFLog is the root object of a SARIF 2.1.0 file
+type SARIFLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema,omitempty"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// SARIFRun is the output of a single tool
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+// SARIFTool describes the tool that produced a run
+type SARIFTool struct {
+	Driver     SARIFDriver   `json:"driver"`
+	Extensions []SARIFDriver `json:"extensions,omitempty"`
+}
+
+// SARIFDriver is the component of the tool holding the rules
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []SARIFRule `json:"rules,omitempty"`
+}
+
+// SARIFRule describes a rule reported by the tool
+type SARIFRule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name,omitempty"`
+	ShortDescription     *SARIFMessage          `json:"shortDescription,omitempty"`
+	FullDescription      *SARIFMessage          `json:"fullDescription,omitempty"`
+	Help                 *SARIFMessage          `json:"help,omitempty"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	DefaultConfiguration *SARIFConfiguration    `json:"defaultConfiguration,omitempty"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+// SARIFConfiguration is the default configuration of a rule
+type SARIFConfiguration struct {
+	Level string `json:"level,omitempty"`
+}
+
+// SARIFMessage is a plain text message
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFResult is a single finding
+type SARIFResult struct {
+	RuleID              string                 `json:"ruleId,omitempty"`
+	RuleIndex           *int                   `json:"ruleIndex,omitempty"`
+	Level               string                 `json:"level,omitempty"`
+	Message             SARIFMessage           `json:"message"`
+	Locations           []SARIFLocation        `json:"locations,omitempty"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Suppressions        []SARIFSuppression     `json:"suppressions,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+// SARIFLocation is where a result was found
+type SARIFLocation struct {
+	PhysicalLocation *SARIFPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []SARIFLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+// SARIFPhysicalLocation is a file and region
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           *SARIFRegion          `json:"region,omitempty"`
+}
+
+// SARIFArtifactLocation is the URI of a file
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SARIFRegion is a line range of a file
+type SARIFRegion struct {
+	StartLine int           `json:"startLine,omitempty"`
+	EndLine   int           `json:"endLine,omitempty"`
+	Snippet   *SARIFMessage `json:"snippet,omitempty"`
+}
+
+// SARIFLogicalLocation is a named location such as a method, a package or an image
+type SARIFLogicalLocation struct {
+	Name               string `json:"name,omitempty"`
+	FullyQualifiedName string `json:"fullyQualifiedName,omitempty"`
+	Kind               string `json:"kind,omitempty"`
+}
+
+// SARIFSuppression records that a result was accepted
+type SARIFSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status,omitempty"`
+	Justification string `json:"justification,omitempty"`
+}
+
+// writeSARIF writes the evaluated findings as a SARIF 2.1.0 log, one run per report.
+// Suppressed findings carry a SARIF suppression, warnings are reported with the warning level.
+func writeSARIF(filename string, res *Result, redactor Redactor) error {
+	sarif := SARIFLog{Version: sarifVersion, Schema: sarifSchema, Runs: []SARIFRun{}}
+
+	for _, rr := range res.Reports {
+		run := SARIFRun{
+			Tool: SARIFTool{Driver: SARIFDriver{
+				Name:           rr.Report.Scan.Scanner.Name,
+				Version:        rr.Report.Scan.Scanner.Version,
+				InformationURI: rr.Report.Scan.Scanner.URL,
+			}},
+			Results: []SARIFResult{},
+		}
+		if run.Tool.Driver.Name == "" {
+			run.Tool.Driver.Name = string(rr.Category())
+		}
+
+		rules := make(map[string]int)
+		for _, f := range rr.Findings {
+			v := redactor.Vulnerability(f.Vulnerability)
+
+			ruleID := primaryIdentifier(v)
+			index, ok := rules[ruleID]
+			if !ok {
+				index = len(run.Tool.Driver.Rules)
+				rules[ruleID] = index
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule(ruleID, v))
+			}
+
+			result := SARIFResult{
+				RuleID:    ruleID,
+				RuleIndex: &index,
+				Level:     sarifLevel(f.Decision),
+				Message:   SARIFMessage{Text: sarifText(v)},
+				Locations: sarifLocations(v.Location),
+				PartialFingerprints: map[string]string{
+					"gitlabSecurityReportGate/v1": fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(fingerprints(f), ",")))),
+				},
+				Properties: map[string]interface{}{
+					"category":   string(v.Category),
+					"severity":   severityOf(v).String(),
+					"confidence": confidenceOf(v).String(),
+					"decision":   f.Decision.String(),
+					"reason":     f.Reason,
+					"id":         f.ID(),
+				},
+			}
+			if f.Decision == DecisionSuppress {
+				s := SARIFSuppression{Kind: "external", Status: "accepted", Justification: f.Reason}
+				if f.Suppression != nil {
+					s.Justification = f.Suppression.Justification
+				}
+				result.Suppressions = []SARIFSuppression{s}
+			}
+
+			run.Results = append(run.Results, result)
+		}
+
+		sarif.Runs = append(sarif.Runs, run)
+	}
+
+	b, err := json.MarshalIndent(sarif, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0644)
+}
+
+// sarifRule builds a rule from the identifiers of a vulnerability
+func sarifRule(id string, v report.Vulnerability) SARIFRule {
+	rule := SARIFRule{
+		ID:                   id,
+		Name:                 v.Name,
+		ShortDescription:     &SARIFMessage{Text: v.Name},
+		DefaultConfiguration: &SARIFConfiguration{Level: sarifSeverityLevel(severityOf(v))},
+	}
+	if v.Description != "" {
+		rule.FullDescription = &SARIFMessage{Text: v.Description}
+	}
+	if v.Solution != "" {
+		rule.Help = &SARIFMessage{Text: v.Solution}
+	}
+
+	var tags []string
+	for _, identifier := range v.Identifiers {
+		if rule.HelpURI == "" && identifier.URL != "" {
+			rule.HelpURI = identifier.URL
+		}
+		tags = append(tags, identifier.Name)
+	}
+	if len(tags) > 0 {
+		rule.Properties = map[string]interface{}{"tags": tags}
+	}
+	return rule
+}
+
+func sarifText(v report.Vulnerability) string {
+	if v.Message != "" {
+		return v.Message
+	}
+	if v.Description != "" {
+		return v.Description
+	}
+	return v.Name
+}
+
+func sarifLocations(l report.Location) []SARIFLocation {
+	var loc SARIFLocation
+	if l.File != "" {
+		loc.PhysicalLocation = &SARIFPhysicalLocation{
+			ArtifactLocation: SARIFArtifactLocation{URI: strings.TrimPrefix(l.File, "./")},
+		}
+		if l.LineStart > 0 {
+			loc.PhysicalLocation.Region = &SARIFRegion{StartLine: l.LineStart, EndLine: l.LineEnd}
+		}
+	}
+	if l.Class != "" || l.Method != "" {
+		name := strings.Trim(l.Class+"."+l.Method, ".")
+		loc.LogicalLocations = append(loc.LogicalLocations, SARIFLogicalLocation{Name: name, Kind: "function"})
+	}
+	if l.Image != "" {
+		loc.LogicalLocations = append(loc.LogicalLocations, SARIFLogicalLocation{Name: l.Image, Kind: "module"})
+	}
+	if l.Dependency != nil && l.Dependency.Package.Name != "" {
+		name := l.Dependency.Package.Name
+		if l.Dependency.Version != "" {
+			name += "@" + l.Dependency.Version
+		}
+		loc.LogicalLocations = append(loc.LogicalLocations, SARIFLogicalLocation{Name: name, Kind: "package"})
+	}
+	if loc.PhysicalLocation == nil && len(loc.LogicalLocations) == 0 {
+		return nil
+	}
+	return []SARIFLocation{loc}
+}
+
+// sarifLevel maps a gate decision onto a SARIF result level
+func sarifLevel(d Decision) string {
+	switch d {
+	case DecisionFail:
+		return "error"
+	case DecisionWarn:
+		return "warning"
+	}
+	return "note"
+}
+
+// sarifSeverityLevel maps a severity onto the default level of a SARIF rule
+func sarifSeverityLevel(s report.SeverityLevel) string {
+	switch {
+	case s >= report.SeverityLevelHigh:
+		return "error"
+	case s >= report.SeverityLevelLow:
+		return "warning"
+	}
+	return "note"
+}
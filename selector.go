@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// selector is a compiled JSONPath-like expression. It supports the root ($), child names
+// (.name or ['name']), array indexes ([0], negative from the end) and wildcards (.* or [*]).
+type selector []step
+
+// step is a single segment of a selector, either a key, an index or a wildcard
+type step struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// compileSelector parses a selector, a missing leading $ is implied
+func compileSelector(path string) (selector, error) {
+	s := strings.TrimSpace(path)
+	if s == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+	s = strings.TrimPrefix(s, "$")
+	if s != "" && s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	var sel selector
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end == -1 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid selector %q: empty name", path)
+			}
+			if name == "*" {
+				sel = append(sel, step{wildcard: true})
+			} else {
+				sel = append(sel, step{key: name})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid selector %q: missing ]", path)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			switch {
+			case inner == "*":
+				sel = append(sel, step{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				sel = append(sel, step{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid selector %q: bad index %q", path, inner)
+				}
+				sel = append(sel, step{index: n, isIndex: true})
+			}
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid selector %q at %q", path, s)
+		}
+	}
+	return sel, nil
+}
+
+// Select returns every value of the document matched by the selector
+func (sel selector) Select(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, st := range sel {
+		var next []interface{}
+		for _, node := range current {
+			switch n := node.(type) {
+			case map[string]interface{}:
+				if st.wildcard {
+					keys := make([]string, 0, len(n))
+					for k := range n {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, n[k])
+					}
+				} else if v, ok := n[st.key]; ok && !st.isIndex {
+					next = append(next, v)
+				}
+			case []interface{}:
+				switch {
+				case st.wildcard:
+					next = append(next, n...)
+				case st.isIndex:
+					i := st.index
+					if i < 0 {
+						i += len(n)
+					}
+					if i >= 0 && i < len(n) {
+						next = append(next, n[i])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
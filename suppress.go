@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultSuppressionsPath is the checked-in allowlist loaded when none is given
+const defaultSuppressionsPath = ".gitlab/security-gate.yml"
+
+// dateFormat is the format of suppression expiry dates
+const dateFormat = "2006-01-02"
+
+// Suppression is an accepted finding. Every matcher set on the suppression must match.
+type Suppression struct {
+	ID            string `yaml:"id,omitempty"`          // ID matches report.Vulnerability.ID()
+	CompareKey    string `yaml:"compare_key,omitempty"` // CompareKey matches report.Vulnerability.CompareKey
+	Identifier    string `yaml:"identifier,omitempty"`  // Identifier matches the value of any identifier of the vulnerability
+	Path          string `yaml:"path,omitempty"`        // Path matches the location file, doublestar glob patterns are supported
+	Justification string `yaml:"justification"`         // Justification explains why the finding is accepted
+	Approver      string `yaml:"approver"`              // Approver is who accepted the finding
+	Expires       string `yaml:"expires"`               // Expires is the date (YYYY-MM-DD) after which the suppression stops applying
+
+	expires time.Time
+}
+
+// SuppressionFile is the allowlist of accepted findings
+type SuppressionFile struct {
+	Suppressions []*Suppression `yaml:"suppressions"`
+}
+
+// loadSuppressions reads and validates a suppression file.
+// A missing file is only an error when required is set.
+func loadSuppressions(filename string, required bool) (*SuppressionFile, error) {
+	sf := &SuppressionFile{}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return sf, nil
+		}
+		return nil, err
+	}
+
+	// a misspelled matcher would otherwise widen the suppression
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(sf); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	for i, s := range sf.Suppressions {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("%s: suppression #%d: %w", filename, i+1, err)
+		}
+	}
+
+	return sf, nil
+}
+
+func (s *Suppression) validate() error {
+	if s.ID == "" && s.CompareKey == "" && s.Identifier == "" && s.Path == "" {
+		return errors.New("one of id, compare_key, identifier or path is required")
+	}
+	if s.Justification == "" {
+		return errors.New("justification is required")
+	}
+	if s.Approver == "" {
+		return errors.New("approver is required")
+	}
+	if s.Expires == "" {
+		return errors.New("expires is required")
+	}
+	if s.Path != "" {
+		if !doublestar.ValidatePattern(s.Path) {
+			return fmt.Errorf("invalid path pattern %q", s.Path)
+		}
+	}
+
+	expires, err := time.Parse(dateFormat, s.Expires)
+	if err != nil {
+		return fmt.Errorf("invalid expires date %q, expected YYYY-MM-DD", s.Expires)
+	}
+	s.expires = expires
+
+	return nil
+}
+
+// Expired is true once the expiry date has passed. A suppression is valid through its expiry date.
+func (s *Suppression) Expired(now time.Time) bool {
+	return now.After(s.expires.AddDate(0, 0, 1))
+}
+
+// Matches is true when every matcher set on the suppression matches the finding
+func (s *Suppression) Matches(f *Finding) bool {
+	if s.ID != "" && s.ID != f.ID() {
+		return false
+	}
+	if s.CompareKey != "" && s.CompareKey != f.CompareKey {
+		return false
+	}
+	if s.Identifier != "" && !hasIdentifier(f, s.Identifier) {
+		return false
+	}
+	if s.Path != "" {
+		if ok, _ := doublestar.Match(s.Path, f.Location.File); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Suppression) String() string {
+	var matcher string
+	switch {
+	case s.ID != "":
+		matcher = "id " + s.ID
+	case s.CompareKey != "":
+		matcher = "compare_key " + s.CompareKey
+	case s.Identifier != "":
+		matcher = "identifier " + s.Identifier
+	default:
+		matcher = "path " + s.Path
+	}
+	return fmt.Sprintf("%s approved by %s until %s: %s", matcher, s.Approver, s.Expires, s.Justification)
+}
+
+// Match returns the first active suppression matching the finding.
+// Expired suppressions matching the finding are returned separately so they can be reported.
+func (sf *SuppressionFile) Match(f *Finding, now time.Time) (active *Suppression, expired []*Suppression) {
+	for _, s := range sf.Suppressions {
+		if !s.Matches(f) {
+			continue
+		}
+		if s.Expired(now) {
+			expired = append(expired, s)
+			continue
+		}
+		return s, expired
+	}
+	return nil, expired
+}
+
+func hasIdentifier(f *Finding, value string) bool {
+	for _, id := range f.Identifiers {
+		if id.Value == value {
+			return true
+		}
+	}
+	return false
+}
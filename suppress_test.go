@@ -0,0 +1,165 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/gitlab-org/security-products/analyzers/report/v2"
+)
+
+func TestLoadSuppressions(t *testing.T) {
+	const valid = "justification: test fixture\n    approver: security\n    expires: 2024-06-30\n"
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", "suppressions:\n  - path: \"test/**\"\n    " + valid, ""},
+		{"empty", "", ""},
+		{"no matcher", "suppressions:\n  - " + valid, "one of id, compare_key, identifier or path is required"},
+		{"no justification", "suppressions:\n  - id: abc\n    approver: security\n    expires: 2024-06-30\n", "justification is required"},
+		{"no approver", "suppressions:\n  - id: abc\n    justification: test\n    expires: 2024-06-30\n", "approver is required"},
+		{"no expiry", "suppressions:\n  - id: abc\n    justification: test\n    approver: security\n", "expires is required"},
+		{"invalid expiry", "suppressions:\n  - id: abc\n    justification: test\n    approver: security\n    expires: 30/06/2024\n", `invalid expires date "30/06/2024"`},
+		{"invalid pattern", "suppressions:\n  - path: \"test/[\"\n    " + valid, `invalid path pattern "test/["`},
+		{"misspelled matcher", "suppressions:\n  - compare-key: abc\n    " + valid, "field compare-key not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "security-gate.yml")
+			writeFile(t, path, tt.content)
+
+			_, err := loadSuppressions(path, true)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestLoadSuppressionsMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "security-gate.yml")
+
+	if sf, err := loadSuppressions(path, false); err != nil || len(sf.Suppressions) != 0 {
+		t.Errorf("optional file: got %v, %v, want no suppressions", sf, err)
+	}
+	if _, err := loadSuppressions(path, true); err == nil {
+		t.Error("required file: got no error")
+	}
+}
+
+func TestSuppressionExpired(t *testing.T) {
+	s := &Suppression{ID: "abc", Justification: "test", Approver: "security", Expires: "2024-06-30"}
+	if err := s.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		now  string
+		want bool
+	}{
+		{"2024-06-29T12:00:00Z", false},
+		{"2024-06-30T00:00:00Z", false},
+		{"2024-06-30T23:59:59Z", false},
+		{"2024-07-01T00:00:00Z", false},
+		{"2024-07-01T00:00:01Z", true},
+		{"2025-01-01T00:00:00Z", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.now, func(t *testing.T) {
+			now, err := time.Parse(time.RFC3339, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Expired(now); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuppressionMatches(t *testing.T) {
+	f := &Finding{Vulnerability: report.Vulnerability{
+		Category:   report.CategorySast,
+		Name:       "SQL injection",
+		CompareKey: "app/db/query.go:gosec:G201:12",
+		Location:   report.Location{File: "app/db/query.go", LineStart: 12},
+		Identifiers: []report.Identifier{
+			{Type: "gosec_rule_id", Name: "Gosec Rule ID G201", Value: "G201"},
+			report.CWEIdentifier(89),
+		},
+	}}
+
+	tests := []struct {
+		name string
+		s    Suppression
+		want bool
+	}{
+		{"id", Suppression{ID: f.ID()}, true},
+		{"other id", Suppression{ID: "0123abcd"}, false},
+		{"compare key", Suppression{CompareKey: f.CompareKey}, true},
+		{"compare key prefix", Suppression{CompareKey: "app/db/query.go"}, false},
+		{"identifier", Suppression{Identifier: "G201"}, true},
+		{"second identifier", Suppression{Identifier: "89"}, true},
+		{"identifier name", Suppression{Identifier: "Gosec Rule ID G201"}, false},
+		{"path", Suppression{Path: "app/db/query.go"}, true},
+		{"path glob", Suppression{Path: "app/**/*.go"}, true},
+		{"path any depth", Suppression{Path: "**/query.go"}, true},
+		{"path single level", Suppression{Path: "app/*.go"}, false},
+		{"path directory", Suppression{Path: "app/db"}, false},
+		{"every matcher", Suppression{Identifier: "G201", Path: "app/**"}, true},
+		{"one matcher differs", Suppression{Identifier: "G201", Path: "test/**"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.Matches(f); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuppressionFileMatch(t *testing.T) {
+	suppression := func(identifier, expires string) *Suppression {
+		s := &Suppression{Identifier: identifier, Justification: "test", Approver: "security", Expires: expires}
+		if err := s.validate(); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	expired := suppression("G201", "2024-01-31")
+	active := suppression("G201", "2024-12-31")
+	later := suppression("G201", "2025-12-31")
+	other := suppression("G104", "2024-12-31")
+	sf := &SuppressionFile{Suppressions: []*Suppression{other, expired, active, later}}
+
+	f := &Finding{Vulnerability: report.Vulnerability{
+		Identifiers: []report.Identifier{{Type: "gosec_rule_id", Value: "G201"}},
+	}}
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	gotActive, gotExpired := sf.Match(f, now)
+	if gotActive != active {
+		t.Errorf("active: got %v, want %v", gotActive, active)
+	}
+	if len(gotExpired) != 1 || gotExpired[0] != expired {
+		t.Errorf("expired: got %v, want [%v]", gotExpired, expired)
+	}
+
+	gotActive, gotExpired = sf.Match(f, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC))
+	if gotActive != nil || len(gotExpired) != 3 {
+		t.Errorf("after every expiry: got %v, %v", gotActive, gotExpired)
+	}
+}